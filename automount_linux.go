@@ -0,0 +1,58 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const udevRulesDir = "/run/udev/rules.d"
+
+// suppressAutomount adds a udev rule telling udisks, and the desktop
+// automounters built on it, to ignore the target's disk and its partitions
+// while they are wiped, so a changed partition table does not get mounted
+// again. The returned function removes the rule and lets udev look at the
+// disk afresh.
+func suppressAutomount(t WipeTarget) (func(), error) {
+	path := t.Disk
+	if path == "" {
+		path = t.Path
+	}
+	dir, err := sysfsBlockDir(path)
+	if err != nil {
+		// Image files are never automounted.
+		return func() {}, nil
+	}
+	if _, err := exec.LookPath("udevadm"); err != nil {
+		// Without udev there is no automounter to keep away.
+		return func() {}, nil
+	}
+	name := filepath.Base(dir)
+	if err := os.MkdirAll(udevRulesDir, 0o755); err != nil {
+		return nil, err
+	}
+	rule := filepath.Join(udevRulesDir, "90-wipr-"+name+".rules")
+	content := fmt.Sprintf("# Added by Wipr (PID %d) while /dev/%s is wiped.\n"+
+		"SUBSYSTEM==\"block\", KERNELS==\"%s\", ENV{UDISKS_IGNORE}=\"1\", ENV{UDISKS_AUTO}=\"0\"\n", os.Getpid(), name, name)
+	if err := os.WriteFile(rule, []byte(content), 0o644); err != nil {
+		return nil, err
+	}
+	udevadm("control", "--reload")
+	return func() {
+		if err := os.Remove(rule); err != nil {
+			log.Println(err)
+		}
+		udevadm("control", "--reload")
+		udevadm("trigger", "--action=change", dir)
+	}, nil
+}
+
+func udevadm(args ...string) {
+	if out, err := exec.Command("udevadm", args...).CombinedOutput(); err != nil {
+		log.Println("udevadm", args, err, string(out))
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+)
+
+// runCommand runs Wipr without its window when the first argument names a
+// command. It reports whether it did, and the exit status.
+func runCommand(args []string) (bool, int) {
+	if len(args) == 0 {
+		return false, 0
+	}
+	switch args[0] {
+	case "wipe":
+		if err := wipeCommand(args[1:]); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "wipr:", err)
+			}
+			return true, 1
+		}
+		return true, 0
+	}
+	return false, 0
+}
+
+// wipeCommand wipes the devices named on the command line. It is the only
+// way to wipe a disk that hosts the running system: -allow-system-disk
+// lifts that protection, which the window never does.
+func wipeCommand(args []string) error {
+	flags := flag.NewFlagSet("wipr wipe", flag.ContinueOnError)
+	methodID := flags.String("method", defaultMethod.ID, "wipe method ID")
+	percent := flags.Int("verify-percent", config.VerifyPercent, "share of blocks read back after each verified pass")
+	unmount := flags.Bool("unmount", false, "unmount filesystems and swap off swap on the targets")
+	teardown := flags.Bool("teardown", false, "deactivate LVM, md and device-mapper stacks and wipe all of their members")
+	allowSystem := flags.Bool("allow-system-disk", false, "allow wiping the disks hosting the running system")
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: wipr wipe [flags] device...")
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "\nmethods:")
+		for _, m := range WipeMethods() {
+			fmt.Fprintf(flags.Output(), "  %-18s %s\n", m.ID, m.Name)
+		}
+	}
+	if err := loadRecipes(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no devices given")
+	}
+	method := MethodByID(*methodID)
+	if method == nil {
+		return fmt.Errorf("unknown method %q", *methodID)
+	}
+
+	drives, disks := List_Drives()
+	maps.Copy(driveMap, disks)
+	_, parts := List_Partitions()
+	maps.Copy(partitionMap, parts)
+	if len(drives) == 0 {
+		return errors.New("no drives found")
+	}
+	targets := []WipeTarget{}
+	for _, path := range flags.Args() {
+		t, ok := targetByPath(path)
+		if !ok {
+			return fmt.Errorf("%s: not a listed drive or partition", path)
+		}
+		targets = append(targets, t)
+	}
+	targets, stacks, err := gatherStacks(targets)
+	if err != nil {
+		return err
+	}
+	if len(stacks) > 0 && !*teardown {
+		return fmt.Errorf("wiping only part of a storage stack leaves its data recoverable on the other members; pass -teardown to wipe all of it:\n%s", strings.Join(stacks, "\n"))
+	}
+
+	for _, t := range targets {
+		if err := method.Check(t); err != nil {
+			return fmt.Errorf("%s: %w", t.Path, err)
+		}
+		if err := checkProtected(t); err != nil {
+			if !*allowSystem {
+				return fmt.Errorf("%w; pass -allow-system-disk to wipe it anyway", err)
+			}
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+		mounts, err := targetMounts(t)
+		if err != nil {
+			return err
+		}
+		if len(mounts) > 0 && !*unmount {
+			list := []string{}
+			for _, m := range mounts {
+				list = append(list, m.String())
+			}
+			return fmt.Errorf("%s is in use (%s); pass -unmount to unmount it", t.Path, strings.Join(list, ", "))
+		}
+	}
+
+	fmt.Printf("Wiping with %s (%s):\n", method.Name, method.Standard)
+	for _, t := range stacks {
+		fmt.Println("  " + t)
+	}
+	for _, t := range targets {
+		fmt.Printf("  %s, %s, %s\n", targetTitle(t), formatBytes(t.Size), t.ID)
+	}
+	if !*yes {
+		in := bufio.NewScanner(os.Stdin)
+		for _, t := range targets {
+			text, prompt := confirmText(t)
+			fmt.Printf("All data on %s will be destroyed. %s ", t.Path, prompt)
+			if !in.Scan() || strings.TrimSpace(in.Text()) != text {
+				return errors.New("not confirmed")
+			}
+		}
+	}
+
+	jobs := make([]WipeJob, len(targets))
+	ctls := make([]*jobControl, len(targets))
+	for i, t := range targets {
+		jobs[i] = WipeJob{Target: t, Method: method, VerifyPercent: *percent, AllowSystemDisk: *allowSystem, Unmount: *unmount, Teardown: *teardown}
+		ctls[i] = newJobControl()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		for range interrupt {
+			fmt.Println("cancelling...")
+			for _, ctl := range ctls {
+				ctl.Cancel()
+			}
+		}
+	}()
+	var mu sync.Mutex
+	shown := make([]int, len(jobs))
+	results := runJobs(jobs, ctls, func(i int, p WipeProgress) {
+		if p.Total == 0 {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// One line per job every 10 percent.
+		if step := int(p.Done * 10 / p.Total); step > shown[i] {
+			shown[i] = step
+			fmt.Printf("%s: pass %d / %d, %d%%\n", targetTitle(jobs[i].Target), p.Pass, p.Passes, step*10)
+		}
+	}, nil)
+	title, msg := wipeSummary(results, method)
+	fmt.Println(title + ": " + msg)
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.New("not every target was wiped")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// confirmTailLen is how many trailing characters of a serial number the
+	// operator types to confirm a wipe.
+	confirmTailLen = 4
+	abortSeconds   = 5
+)
+
+// deviceName is the last element of a device path: sdb1, or PhysicalDrive1
+// for \\.\PhysicalDrive1.
+func deviceName(path string) string {
+	path = strings.TrimPrefix(path, `\\.\`)
+	return path[strings.LastIndexAny(path, `/\`)+1:]
+}
+
+// confirmText is what the operator types to confirm wiping t: the end of
+// its disk's serial number, or its device name where there is no serial.
+func confirmText(t WipeTarget) (text, prompt string) {
+	if serial := []rune(t.ID.Serial); len(serial) > 0 {
+		if len(serial) > confirmTailLen {
+			serial = serial[len(serial)-confirmTailLen:]
+		}
+		return string(serial), fmt.Sprintf("Type the last %d characters of its serial number:", len(serial))
+	}
+	name := partitionName(t)
+	return name, "Type its device name, " + name + ":"
+}
+
+// diskModel is the model of the disk at path, as listed in the drive list.
+func diskModel(path string) string {
+	for _, d := range driveMap {
+		if diskTarget(d).Path == path {
+			return d.Model
+		}
+	}
+	return ""
+}
+
+// targetSummary lists a target's disk and every partition that will be
+// destroyed with it.
+func targetSummary(t WipeTarget, mounts map[string][]string) fyne.CanvasObject {
+	title := fmt.Sprintf("Disk %s: %s", t.Path, t.Name)
+	parts := t.Parts
+	switch {
+	case t.Offset > 0:
+		title = fmt.Sprintf("Partition %s on %s", t.Name, t.Path)
+		parts = []WipeTarget{t}
+	case t.Disk == "":
+		title = "Image " + t.Path
+	case t.Path != t.Disk:
+		title = fmt.Sprintf("Partition %s on %s", t.Path, diskModel(t.Disk))
+		parts = []WipeTarget{t}
+	}
+	box := container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(fmt.Sprintf("%s, %s", formatBytes(t.Size), t.ID)),
+	)
+	if len(parts) == 0 {
+		box.Add(widget.NewLabel("No partitions"))
+		return box
+	}
+	grid := container.NewGridWithColumns(6)
+	for _, h := range []string{"Partition", "Size", "Filesystem", "Label", "Mounted at", "Used"} {
+		grid.Add(widget.NewLabelWithStyle(h, fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+	}
+	for _, p := range parts {
+		used := "-"
+		points := mounts[p.Path]
+		if len(points) > 0 {
+			if stats, err := fsUsage(points[0]); err == nil {
+				used = fmt.Sprintf("%s of %s", formatBytes(stats.TotalBytes-stats.FreeBytes), formatBytes(stats.TotalBytes))
+			}
+		}
+		grid.Add(widget.NewLabel(partitionName(p)))
+		grid.Add(widget.NewLabel(formatBytes(p.Size)))
+		grid.Add(widget.NewLabel(ternary(p.FSType != "", fsTypeName(p.FSType, p.FSVersion), "unknown")))
+		grid.Add(widget.NewLabel(ternary(p.Label != "", p.Label, "-")))
+		grid.Add(widget.NewLabel(ternary(len(points) > 0, strings.Join(points, ", "), "not mounted")))
+		grid.Add(widget.NewLabel(used))
+	}
+	box.Add(grid)
+	return box
+}
+
+// confirmSection is one thing listed in a confirmation dialog, with the
+// text the operator types to confirm destroying it.
+type confirmSection struct {
+	Summary fyne.CanvasObject
+	Text    string
+	Prompt  string
+}
+
+// confirmWipe shows exactly what is about to be destroyed and enables the
+// Wipe button only once the operator has typed the confirmation text of
+// every target. A short countdown follows, during which the wipe can still
+// be aborted, before start is called.
+func confirmWipe(window fyne.Window, targets []WipeTarget, inUse []targetMount, signatures []string, start func()) {
+	mounts := map[string][]string{}
+	for _, m := range inUse {
+		if !m.Swap {
+			mounts[m.Device] = append(mounts[m.Device], m.MountPoint)
+		}
+	}
+	sections := []confirmSection{}
+	for _, t := range targets {
+		text, prompt := confirmText(t)
+		sections = append(sections, confirmSection{Summary: targetSummary(t, mounts), Text: text, Prompt: prompt})
+	}
+	notes := []fyne.CanvasObject{}
+	if len(inUse) > 0 {
+		notes = append(notes, widget.NewLabel("These will be unmounted first:\n"+mountList(inUse)))
+	}
+	if len(signatures) > 0 {
+		notes = append(notes, widget.NewLabel("These signatures will be erased before the first pass:\n"+strings.Join(signatures, "\n")))
+	} else {
+		notes = append(notes, widget.NewLabel("No known signatures were found."))
+	}
+	confirmDestroy(window, "Wipe", sections, notes, start)
+}
+
+// confirmShred lists the files and folders about to be shredded, with what
+// is inside the folders, and asks for the same typed confirmation and
+// countdown as a wipe: the name of the file or folder, or the number of
+// them when there are several.
+func confirmShred(window fyne.Window, items []shredItem, start func()) {
+	grid := container.NewGridWithColumns(3)
+	for _, h := range []string{"Path", "Contents", "Size"} {
+		grid.Add(widget.NewLabelWithStyle(h, fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+	}
+	var files, skipped int
+	var size uint64
+	for _, item := range items {
+		contents := "file"
+		if item.Dir {
+			contents = fmt.Sprintf("folder, %d files", item.Files)
+		}
+		if item.Skipped > 0 {
+			contents += fmt.Sprintf(", %d skipped", item.Skipped)
+		}
+		path := widget.NewLabel(item.Path)
+		path.Wrapping = fyne.TextWrapBreak
+		grid.Add(path)
+		grid.Add(widget.NewLabel(contents))
+		grid.Add(widget.NewLabel(formatBytes(item.Size)))
+		files += item.Files
+		skipped += item.Skipped
+		size += item.Size
+	}
+	summary := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("%d files, %s, will be overwritten and deleted", files, formatBytes(size)), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		grid,
+	)
+	text := fmt.Sprint(len(items))
+	prompt := fmt.Sprintf("Type the number of items selected, %s:", text)
+	if len(items) == 1 {
+		text = filepath.Base(items[0].Path)
+		prompt = "Type its name, " + text + ":"
+	}
+	notes := []fyne.CanvasObject{widget.NewLabel("Folders are removed once emptied.")}
+	if skipped > 0 {
+		notes = append(notes, widget.NewLabel("Symlinks and special files are never followed or overwritten, and are left in place."))
+	}
+	confirmDestroy(window, "Shred", []confirmSection{{Summary: summary, Text: text, Prompt: prompt}}, notes, start)
+}
+
+// confirmDestroy shows the sections and notes, and enables the action
+// button only once every section's confirmation text has been typed. The
+// abort countdown follows before start is called.
+func confirmDestroy(window fyne.Window, action string, sections []confirmSection, notes []fyne.CanvasObject, start func()) {
+	actionBtn := widget.NewButton(action, nil)
+	actionBtn.Importance = widget.DangerImportance
+	actionBtn.Disable()
+
+	entries := []*widget.Entry{}
+	check := func(string) {
+		for i, e := range entries {
+			if !strings.EqualFold(strings.TrimSpace(e.Text), sections[i].Text) {
+				actionBtn.Disable()
+				return
+			}
+		}
+		actionBtn.Enable()
+	}
+	content := container.NewVBox()
+	for _, s := range sections {
+		entry := widget.NewEntry()
+		entry.OnChanged = check
+		entries = append(entries, entry)
+		content.Add(s.Summary)
+		content.Add(widget.NewLabel(s.Prompt))
+		content.Add(entry)
+		content.Add(widget.NewSeparator())
+	}
+	for _, n := range notes {
+		content.Add(n)
+	}
+	scroll := container.NewVScroll(content)
+	scroll.SetMinSize(fyne.NewSize(720, 420))
+
+	d := dialog.NewCustomWithoutButtons("Everything listed here will be destroyed", scroll, window)
+	actionBtn.OnTapped = func() {
+		d.Hide()
+		abortCountdown(window, action, start)
+	}
+	d.SetButtons([]fyne.CanvasObject{widget.NewButton("Cancel", d.Hide), actionBtn})
+	d.Show()
+	if len(entries) > 0 {
+		window.Canvas().Focus(entries[0])
+	}
+}
+
+// abortCountdown counts down abortSeconds with an Abort button, then calls
+// start unless the operator aborted. action names what is starting.
+func abortCountdown(window fyne.Window, action string, start func()) {
+	label := widget.NewLabel("")
+	d := dialog.NewCustom("Starting "+strings.ToLower(action), "Abort", label, window)
+	// Only touched from the UI goroutine.
+	aborted := false
+	d.SetOnClosed(func() { aborted = true })
+	d.Show()
+	go func() {
+		for left := abortSeconds; left > 0; left-- {
+			fyne.Do(func() {
+				label.SetText(fmt.Sprintf("The %s starts in %d seconds.", strings.ToLower(action), left))
+			})
+			time.Sleep(time.Second)
+		}
+		fyne.Do(func() {
+			if aborted {
+				return
+			}
+			d.Hide()
+			start()
+		})
+	}()
+}
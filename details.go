@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// detailsPanel shows what is known about the disk selected in the target
+// list and the wipe method that suits it.
+type detailsPanel struct {
+	box       *fyne.Container
+	grid      *fyne.Container
+	layout    *widget.Label
+	suggested *widget.Label
+	use       *widget.Button
+	method    *WipeMethod
+	// shown counts the calls to Show, so that details read in the
+	// background for a disk no longer selected are dropped.
+	shown int
+	// OnUse is called with the suggested method when the operator picks it.
+	OnUse func(*WipeMethod)
+}
+
+func newDetailsPanel() *detailsPanel {
+	p := &detailsPanel{
+		grid:      container.NewGridWithColumns(4),
+		layout:    widget.NewLabel(""),
+		suggested: widget.NewLabel(""),
+	}
+	p.use = widget.NewButton("Use", func() {
+		if p.OnUse != nil && p.method != nil {
+			p.OnUse(p.method)
+		}
+	})
+	p.box = container.NewVBox(p.grid, p.layout, container.NewHBox(p.suggested, p.use))
+	p.Show("")
+	return p
+}
+
+// Show fills the panel in for the disk with the given driveMap key, or
+// clears it. The details are read in the background, since that opens
+// every partition on the disk.
+func (p *detailsPanel) Show(disk string) {
+	p.shown++
+	shown := p.shown
+	p.grid.RemoveAll()
+	p.suggested.Hide()
+	p.use.Hide()
+	p.method = nil
+	d, ok := driveMap[disk]
+	if !ok {
+		p.layout.SetText("Select a disk or partition to see its details.")
+		return
+	}
+	p.layout.SetText("Reading " + diskTarget(d).Path + "...")
+	go func() {
+		info := readDeviceInfo(d)
+		target := diskTarget(d)
+		target.Rotational = info.Rotational
+		method := suggestedMethod(target)
+		fyne.Do(func() {
+			if shown == p.shown {
+				p.fill(info, method)
+			}
+		})
+	}()
+}
+
+func (p *detailsPanel) fill(info deviceInfo, method *WipeMethod) {
+	yesNo := func(b bool) string { return ternary(b, "yes", "no") }
+	sector := func(n uint64) string { return ternary(n > 0, fmt.Sprintf("%d bytes", n), "unknown") }
+	for _, row := range [][2]string{
+		{"Device", info.Path},
+		{"Size", formatBytes(info.Size)},
+		{"Model", ternary(info.Model != "", info.Model, "unknown")},
+		{"Vendor", ternary(info.Vendor != "", info.Vendor, "unknown")},
+		{"Serial", ternary(info.Serial != "", info.Serial, "none")},
+		{"WWN", ternary(info.WWN != "", info.WWN, "none")},
+		{"Transport", ternary(info.Transport != "", info.Transport, "unknown")},
+		{"Media", ternary(info.Rotational, "rotational", "solid-state")},
+		{"Logical sector", sector(info.LogicalSector)},
+		{"Physical sector", sector(info.PhysicalSector)},
+		{"Removable", yesNo(info.Removable)},
+		{"Read-only", yesNo(info.ReadOnly)},
+	} {
+		p.grid.Add(widget.NewLabelWithStyle(row[0], fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+		p.grid.Add(widget.NewLabel(row[1]))
+	}
+	if len(info.Partitions) == 0 {
+		p.layout.SetText("No partitions")
+	} else {
+		text := fmt.Sprintf("%d partitions:", len(info.Partitions))
+		for _, part := range info.Partitions {
+			text += "\n" + part.String()
+		}
+		p.layout.SetText(text)
+	}
+
+	p.method = method
+	p.suggested.SetText("Suggested method: " + p.method.Name)
+	p.suggested.Show()
+	p.use.Show()
+	if info.ReadOnly {
+		p.suggested.SetText("The disk is read-only and cannot be wiped.")
+		p.use.Hide()
+	}
+}
+
+// Container wraps the panel in a collapsible section.
+func (p *detailsPanel) Container() fyne.CanvasObject {
+	return widget.NewAccordion(widget.NewAccordionItem("Device Details", p.box))
+}
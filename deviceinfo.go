@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jaypipes/ghw"
+)
+
+// deviceInfo describes a disk for the details panel. The basics come from
+// ghw; transport, sector sizes and flags are read from the system, which
+// knows them better.
+type deviceInfo struct {
+	Path           string
+	Model          string
+	Vendor         string
+	Serial         string
+	WWN            string
+	Size           uint64
+	Transport      string
+	Rotational     bool
+	LogicalSector  uint64
+	PhysicalSector uint64
+	Removable      bool
+	ReadOnly       bool
+	Partitions     []partitionInfo
+}
+
+// partitionInfo places a partition on its disk. HasStart is false where
+// the system cannot tell where it starts.
+type partitionInfo struct {
+	Target   WipeTarget
+	Start    uint64
+	HasStart bool
+}
+
+func (p partitionInfo) String() string {
+	s := fmt.Sprintf("%s  %s", partitionName(p.Target), formatBytes(p.Target.Size))
+	if p.HasStart {
+		s += "  at " + formatBytes(p.Start)
+	}
+	if fs := p.Target.filesystem(); fs != "" {
+		s += "  " + fs
+	}
+	return s
+}
+
+func readDeviceInfo(d *ghw.Disk) deviceInfo {
+	target := diskTarget(d)
+	info := deviceInfo{
+		Path:           target.Path,
+		Model:          ghwValue(d.Model),
+		Vendor:         ghwValue(d.Vendor),
+		Serial:         target.ID.Serial,
+		WWN:            target.ID.WWN,
+		Size:           d.SizeBytes,
+		Transport:      ghwValue(d.StorageController.String()),
+		Rotational:     target.Rotational,
+		PhysicalSector: d.PhysicalBlockSizeBytes,
+		Removable:      d.IsRemovable,
+	}
+	readSystemDeviceInfo(&info)
+	for _, p := range d.Partitions {
+		target := partitionTarget(p)
+		fs, _ := probeFilesystem(target)
+		part := partitionInfo{Target: target.withFilesystem(fs)}
+		part.Start, part.HasStart = partitionStart(part.Target.Path)
+		info.Partitions = append(info.Partitions, part)
+	}
+	return info
+}
+
+// suggestedMethod picks a method that suits the kind of drive: a Purge for
+// LUKS devices, a verified discard for flash that supports it and a verified
+// overwrite otherwise.
+func suggestedMethod(t WipeTarget) *WipeMethod {
+	for _, id := range []string{"nist-purge", "discard", "nist-clear"} {
+		if m := MethodByID(id); m != nil && m.Check(t) == nil {
+			return m
+		}
+	}
+	return defaultMethod
+}
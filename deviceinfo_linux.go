@@ -0,0 +1,69 @@
+//go:build linux
+
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readSystemDeviceInfo fills in what sysfs knows about the disk: the bus it
+// hangs off, going by its place in the device tree, its sector sizes and
+// whether it is rotational, removable or read-only.
+func readSystemDeviceInfo(info *deviceInfo) {
+	dir, err := sysfsBlockDir(info.Path)
+	if err != nil {
+		return
+	}
+	for _, bus := range []struct{ part, name string }{
+		{"/usb", "USB"},
+		{"/nvme", "NVMe"},
+		{"/virtio", "virtio"},
+		{"/ata", "SATA"},
+		{"/mmc", "MMC/SD"},
+		{"/virtual/block/loop", "loop"},
+		{"/virtual/block/dm-", "device-mapper"},
+		{"/virtual/block/md", "md RAID"},
+		{"/host", "SCSI"},
+	} {
+		if strings.Contains(dir, bus.part) {
+			info.Transport = bus.name
+			break
+		}
+	}
+	name := filepath.Base(dir)
+	uintAttr := func(file string) (uint64, bool) {
+		v, err := strconv.ParseUint(sysfsBlockFile(name, file), 10, 64)
+		return v, err == nil
+	}
+	if v, ok := uintAttr("queue/logical_block_size"); ok {
+		info.LogicalSector = v
+	}
+	if v, ok := uintAttr("queue/physical_block_size"); ok {
+		info.PhysicalSector = v
+	}
+	if v, ok := uintAttr("queue/rotational"); ok {
+		info.Rotational = v == 1
+	}
+	if v, ok := uintAttr("removable"); ok {
+		info.Removable = v == 1
+	}
+	if v, ok := uintAttr("ro"); ok {
+		info.ReadOnly = v == 1
+	}
+}
+
+// partitionStart returns the byte offset of a partition on its disk.
+func partitionStart(path string) (uint64, bool) {
+	dir, err := sysfsBlockDir(path)
+	if err != nil {
+		return 0, false
+	}
+	// sysfs counts in 512-byte sectors whatever the disk's sector size.
+	start, err := strconv.ParseUint(sysfsBlockFile(filepath.Base(dir), "start"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start * 512, true
+}
@@ -0,0 +1,107 @@
+//go:build windows
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ioctlDiskGetDriveGeometry    = 0x00070000
+	ioctlDiskIsWritable          = 0x00070024
+	ioctlDiskGetPartitionInfoEx  = 0x00070048
+	ioctlStorageQueryProperty    = 0x002D1400
+	storageDeviceProperty        = 0
+	propertyStandardQuery        = 0
+	mediaTypeRemovable           = 11
+	storageDescriptorBusTypeSize = 32
+)
+
+// busTypes names the STORAGE_BUS_TYPE values a wipe target is likely to have.
+var busTypes = map[uint32]string{
+	0x01: "SCSI",
+	0x03: "ATA",
+	0x07: "USB",
+	0x08: "RAID",
+	0x09: "iSCSI",
+	0x0A: "SAS",
+	0x0B: "SATA",
+	0x0C: "SD",
+	0x0D: "MMC",
+	0x0E: "virtual",
+	0x0F: "virtual",
+	0x11: "NVMe",
+}
+
+func openQueryHandle(path string) (windows.Handle, error) {
+	// No access rights are needed to query a device, only to read or write it.
+	return windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		0,
+		0,
+	)
+}
+
+// readSystemDeviceInfo asks the drive for its bus type, sector size and
+// whether it is removable or write-protected.
+func readSystemDeviceInfo(info *deviceInfo) {
+	handle, err := openQueryHandle(info.Path)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(handle)
+	var returned uint32
+
+	// STORAGE_PROPERTY_QUERY, answered with a STORAGE_DEVICE_DESCRIPTOR.
+	query := [3]uint32{storageDeviceProperty, propertyStandardQuery}
+	desc := make([]byte, 1024)
+	if err := windows.DeviceIoControl(handle, ioctlStorageQueryProperty, (*byte)(unsafe.Pointer(&query)), uint32(unsafe.Sizeof(query)), &desc[0], uint32(len(desc)), &returned, nil); err == nil && returned >= storageDescriptorBusTypeSize {
+		if bus, ok := busTypes[binary.LittleEndian.Uint32(desc[28:])]; ok {
+			info.Transport = bus
+		}
+		info.Removable = info.Removable || desc[10] != 0
+	}
+
+	// DISK_GEOMETRY
+	var geometry struct {
+		Cylinders         int64
+		MediaType         uint32
+		TracksPerCylinder uint32
+		SectorsPerTrack   uint32
+		BytesPerSector    uint32
+	}
+	if err := windows.DeviceIoControl(handle, ioctlDiskGetDriveGeometry, nil, 0, (*byte)(unsafe.Pointer(&geometry)), uint32(unsafe.Sizeof(geometry)), &returned, nil); err == nil {
+		info.LogicalSector = uint64(geometry.BytesPerSector)
+		if info.PhysicalSector == 0 {
+			info.PhysicalSector = info.LogicalSector
+		}
+		info.Removable = info.Removable || geometry.MediaType == mediaTypeRemovable
+	}
+
+	err = windows.DeviceIoControl(handle, ioctlDiskIsWritable, nil, 0, nil, 0, &returned, nil)
+	info.ReadOnly = errors.Is(err, windows.ERROR_WRITE_PROTECT)
+}
+
+// partitionStart returns the byte offset of a volume on its disk.
+func partitionStart(path string) (uint64, bool) {
+	handle, err := openQueryHandle(path)
+	if err != nil {
+		return 0, false
+	}
+	defer windows.CloseHandle(handle)
+	// PARTITION_INFORMATION_EX starts with the style and the offset.
+	var part [144]byte
+	var returned uint32
+	if err := windows.DeviceIoControl(handle, ioctlDiskGetPartitionInfoEx, nil, 0, &part[0], uint32(len(part)), &returned, nil); err != nil {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(part[8:]), true
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const discardChunkSize = 1 << 30
+
+type discardInfo struct {
+	Granularity uint64
+	MaxBytes    uint64
+}
+
+func readDiscardInfo(devPath string) (info discardInfo, err error) {
+	if info.Granularity, err = sysfsQueueUint(devPath, "discard_granularity"); err != nil {
+		return
+	}
+	info.MaxBytes, err = sysfsQueueUint(devPath, "discard_max_bytes")
+	return
+}
+
+// discardPass issues BLKDISCARD or BLKSECDISCARD over the whole target in
+// chunks, so progress can be reported and the job cancelled between them.
+func discardPass(f *deviceFile, size uint64, secure bool, ctl wipeControl, progress func(uint64)) (uint64, error) {
+	req := uintptr(unix.BLKDISCARD)
+	if secure {
+		req = unix.BLKSECDISCARD
+	}
+	var done uint64
+	for done < size {
+		if err := ctl.checkpoint(); err != nil {
+			return done, err
+		}
+		length := min(uint64(discardChunkSize), size-done)
+		r := [2]uint64{f.off + done, length}
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&r))); errno != 0 {
+			return done, errno
+		}
+		done += length
+		progress(done)
+	}
+	return done, nil
+}
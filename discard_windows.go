@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+)
+
+var errDiscardUnsupported = errors.New("discard wiping is only supported on Linux")
+
+type discardInfo struct {
+	Granularity uint64
+	MaxBytes    uint64
+}
+
+func readDiscardInfo(devPath string) (discardInfo, error) {
+	return discardInfo{}, errDiscardUnsupported
+}
+
+func discardPass(f *deviceFile, size uint64, secure bool, ctl wipeControl, progress func(uint64)) (uint64, error) {
+	return 0, errDiscardUnsupported
+}
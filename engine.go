@@ -0,0 +1,452 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const wipeChunkSize = 1 << 20
+
+var errCancelled = errors.New("operation cancelled")
+
+// WipeTarget is a block device (or, for testing, a regular file or loop
+// device) that the engine overwrites from its first to its last byte.
+// Parts are the partitions of a disk, whose signatures are erased too. Disk
+// is the path of the disk a partition lives on, or the disk's own path. ID
+// is checked against the device at Path before anything is written.
+// FSType, FSVersion, Label and FSUUID describe the filesystem on a
+// partition, as read from its superblock or as far as the system knows it.
+// Members are the other devices of an LVM volume group, md array or other
+// device-mapper stack the target belongs to, which are wiped along with it.
+// Offset is where a partition that has no device of its own, such as one on
+// an image file, starts within the device at Path; it is 0 for everything
+// else.
+type WipeTarget struct {
+	Name       string
+	Path       string
+	Disk       string
+	ID         DeviceID
+	Size       uint64
+	Offset     uint64
+	Rotational bool
+	FSType     string
+	FSVersion  string
+	Label      string
+	FSUUID     string
+	Parts      []WipeTarget
+	Members    []WipeTarget
+}
+
+// devices returns the target, its partitions and every member of its stack
+// with their partitions.
+func (t WipeTarget) devices() []WipeTarget {
+	list := append([]WipeTarget{t}, t.Parts...)
+	for _, m := range t.Members {
+		list = append(list, m.devices()...)
+	}
+	return list
+}
+
+// deviceFile is the part of an open device that a target covers: all of it,
+// or the extent of a partition at off.
+type deviceFile struct {
+	*os.File
+	off  uint64
+	size uint64
+}
+
+func (d *deviceFile) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 || uint64(off) >= d.size {
+		return 0, io.EOF
+	}
+	n := min(uint64(len(b)), d.size-uint64(off))
+	read, err := d.File.ReadAt(b[:n], off+int64(d.off))
+	if err == nil && n < uint64(len(b)) {
+		err = io.EOF
+	}
+	return read, err
+}
+
+func (d *deviceFile) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 || uint64(off) > d.size || uint64(len(b)) > d.size-uint64(off) {
+		return 0, fmt.Errorf("write at offset %d: beyond the end of the target", off)
+	}
+	return d.File.WriteAt(b, off+int64(d.off))
+}
+
+// openTargetFile opens the device a target is on and narrows it down to the
+// target's extent.
+func openTargetFile(t WipeTarget) (*deviceFile, error) {
+	f, size, err := openDevice(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	if t.Offset == 0 {
+		return &deviceFile{File: f, size: size}, nil
+	}
+	if t.Offset >= size || t.Size > size-t.Offset {
+		f.Close()
+		return nil, fmt.Errorf("%s: partition at offset %d runs past the end of the device", t.Path, t.Offset)
+	}
+	return &deviceFile{File: f, off: t.Offset, size: t.Size}, nil
+}
+
+// openTarget is a target, or a member of its stack, opened for the passes.
+type openTarget struct {
+	target WipeTarget
+	f      *deviceFile
+	size   uint64
+}
+
+// WipeJob is one target wiped with one method. VerifyPercent is the share
+// of blocks read back after each verified pass; 100 reads everything.
+// AllowSystemDisk overrides the protection of the disks hosting the running
+// system; only wipr wipe -allow-system-disk sets it, never the window.
+// Unmount allows the engine to unmount and swap off whatever uses the
+// target, which is refused otherwise. Teardown allows it to deactivate the
+// device-mapper and md devices built on the target and its members.
+type WipeJob struct {
+	Target          WipeTarget
+	Method          *WipeMethod
+	VerifyPercent   int
+	AllowSystemDisk bool
+	Unmount         bool
+	Teardown        bool
+}
+
+type WipeResult struct {
+	Target        WipeTarget
+	Method        *WipeMethod
+	Passes        int
+	BytesWritten  uint64
+	Verifications []VerifyReport
+	// Signatures were erased before the first pass.
+	Signatures []Signature
+	Err        error
+}
+
+// WipeProgress counts bytes written and bytes read back for verification
+// against the total the job will touch.
+type WipeProgress struct {
+	Pass      int
+	Passes    int
+	Verifying bool
+	Done      uint64
+	Total     uint64
+}
+
+type wipeControl struct {
+	cancel <-chan struct{}
+	pause  <-chan bool
+}
+
+func (c wipeControl) checkpoint() error {
+	select {
+	case <-c.cancel:
+		return errCancelled
+	case <-c.pause:
+		select {
+		case <-c.cancel:
+			return errCancelled
+		case <-c.pause:
+		}
+	default:
+	}
+	return nil
+}
+
+// WipeDevice opens the job's target and runs every pass of its method over
+// all sectors, reading back the passes the method asks to verify. The
+// members of the target's stack are opened too and each pass is written to
+// all of them before the next one starts.
+func WipeDevice(job WipeJob, ctl wipeControl, progress func(WipeProgress)) (result WipeResult) {
+	target, method := job.Target, job.Method
+	result.Target = target
+	result.Method = method
+	if err := method.Check(target); err != nil {
+		result.Err = fmt.Errorf("%s: %w", method.Name, err)
+		return
+	}
+	for _, m := range target.Members {
+		if err := method.Check(m); err != nil {
+			result.Err = fmt.Errorf("%s: %s: %w", m.Path, method.Name, err)
+			return
+		}
+	}
+	stack := append([]WipeTarget{target}, target.Members...)
+	passes, verified, err := preparePasses(method)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	if err := verifyIdentity(target); err != nil {
+		result.Err = err
+		return
+	}
+	if !job.AllowSystemDisk {
+		if err := checkProtected(target); err != nil {
+			result.Err = err
+			return
+		}
+	}
+	locked := map[string]bool{}
+	for _, t := range stack {
+		if locked[lockName(t)] {
+			continue
+		}
+		locked[lockName(t)] = true
+		lock, err := lockDevice(t)
+		if err != nil {
+			result.Err = err
+			return
+		}
+		defer lock.Close()
+		restore, err := suppressAutomount(t)
+		if err != nil {
+			result.Err = fmt.Errorf("suppressing automount: %w", err)
+			return
+		}
+		defer restore()
+	}
+	// Device-mapper and md devices on the target are only holders until
+	// they are torn down below.
+	if err := checkHolders(target, job.Teardown); err != nil {
+		result.Err = err
+		return
+	}
+	held, err := prepareMounts(target, job.Unmount)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	defer releaseDevices(held)
+	if job.Teardown {
+		if err := teardownStack(target); err != nil {
+			result.Err = err
+			return
+		}
+	}
+
+	// Erasing signatures first leaves nothing mountable behind should the
+	// overwrite be interrupted. Partitions go before the table that holds
+	// them, and before the disk is opened, since the disk cannot be opened
+	// exclusively while one of its partitions is.
+	devices := []openTarget{}
+	for _, t := range stack {
+		for _, part := range t.Parts {
+			sigs, err := erasePartSignatures(part, held)
+			result.Signatures = append(result.Signatures, sigs...)
+			if err != nil {
+				result.Err = err
+				return
+			}
+		}
+		f, err := openTargetFile(t)
+		if err != nil {
+			result.Err = err
+			return
+		}
+		defer f.Close()
+		size := f.size
+		if size == 0 {
+			result.Err = fmt.Errorf("%s: device reports a size of 0 bytes", t.Path)
+			return
+		}
+		sigs, err := wipeSignatures(f, t.Path, size)
+		result.Signatures = append(result.Signatures, sigs...)
+		if err != nil {
+			result.Err = err
+			return
+		}
+		devices = append(devices, openTarget{target: t, f: f, size: size})
+	}
+	result.Target.Size = devices[0].size
+	// step names a pass in errors, along with the device once there are
+	// several.
+	step := func(i int, d openTarget) string {
+		if len(devices) > 1 {
+			return fmt.Sprintf("%s: pass %d (%s)", d.target.Path, i+1, passes[i])
+		}
+		return fmt.Sprintf("pass %d (%s)", i+1, passes[i])
+	}
+
+	percent := verifyPercent(job.VerifyPercent)
+	var total uint64
+	for i, pass := range passes {
+		for _, d := range devices {
+			span := d.size
+			if pass.Kind == PassCryptoErase {
+				if span, err = luksSpan(d.f, d.size); err != nil {
+					result.Err = fmt.Errorf("%s: %w", step(i, d), err)
+					return
+				}
+			}
+			total += span
+			if verified[i] {
+				total += span * uint64(percent) / 100
+			}
+		}
+	}
+	var done uint64
+	report := func(pass int, verifying bool) func(uint64) {
+		start := done
+		return func(n uint64) {
+			if progress != nil {
+				progress(WipeProgress{Pass: pass, Passes: len(passes), Verifying: verifying, Done: start + n, Total: total})
+			}
+		}
+	}
+
+	buf := make([]byte, wipeChunkSize)
+	for i, pass := range passes {
+		for _, d := range devices {
+			written, err := writePass(d.f, d.size, pass, buf, ctl, report(i+1, false))
+			result.BytesWritten += written
+			done += written
+			if err != nil {
+				result.Err = fmt.Errorf("%s: %w", step(i, d), err)
+				return
+			}
+			if err := d.f.Sync(); err != nil {
+				result.Err = fmt.Errorf("%s: sync: %w", step(i, d), err)
+				return
+			}
+			if !verified[i] {
+				continue
+			}
+			if err := dropCache(d.f.File); err != nil {
+				result.Err = fmt.Errorf("%s: drop cache: %w", step(i, d), err)
+				return
+			}
+			vr, err := verifyPass(d.f, d.size, pass, percent, ctl, report(i+1, true))
+			vr.Pass = i + 1
+			vr.Device = d.target.Path
+			result.Verifications = append(result.Verifications, vr)
+			done += vr.BytesChecked
+			if err != nil {
+				result.Err = fmt.Errorf("%s: verify: %w", step(i, d), err)
+				return
+			}
+			if len(vr.Mismatches) > 0 {
+				result.Err = fmt.Errorf("%s: %w: %s", step(i, d), errVerifyFailed, vr)
+				return
+			}
+		}
+		result.Passes++
+	}
+	for _, d := range devices {
+		if err := dropCache(d.f.File); err != nil {
+			result.Err = err
+			return
+		}
+		if err := checkSignatures(d.f, d.target.Path, d.size); err != nil {
+			result.Err = err
+			return
+		}
+		if err := checkPartitionTable(d.f, d.target.Path, d.size); err != nil {
+			result.Err = err
+			return
+		}
+	}
+	return
+}
+
+func erasePartSignatures(part WipeTarget, held map[string]heldDevice) ([]Signature, error) {
+	if h, ok := held[part.Path]; ok && part.Offset == 0 {
+		return wipeSignatures(&deviceFile{File: h.f, size: h.size}, part.Path, h.size)
+	}
+	f, err := openTargetFile(part)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return wipeSignatures(f, part.Path, f.size)
+}
+
+// preparePasses resolves the method's passes into the concrete sequence to
+// write and marks which of them must be read back.
+func preparePasses(method *WipeMethod) ([]Pass, []bool, error) {
+	passes, err := resolvePasses(method.Passes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", method.Name, err)
+	}
+	verified := make([]bool, len(passes))
+	for i, pass := range passes {
+		verified[i] = pass.Verify || (method.Verify && i == len(passes)-1)
+	}
+	if err := seedRandomPasses(passes); err != nil {
+		return nil, nil, err
+	}
+	return passes, verified, nil
+}
+
+func verifyPercent(percent int) int {
+	if percent <= 0 || percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// seedRandomPasses gives every random pass without a seed a fresh per-job
+// one, so the data it writes can be regenerated when verifying it.
+func seedRandomPasses(passes []Pass) error {
+	for i := range passes {
+		if passes[i].Kind != PassRandom || passes[i].Seed != "" {
+			continue
+		}
+		seed := make([]byte, 32)
+		if _, err := rand.Read(seed); err != nil {
+			return err
+		}
+		passes[i].Seed = hex.EncodeToString(seed)
+	}
+	return nil
+}
+
+func writePass(f *deviceFile, size uint64, pass Pass, buf []byte, ctl wipeControl, progress func(written uint64)) (uint64, error) {
+	switch pass.Kind {
+	case PassDiscard, PassSecureDiscard:
+		return discardPass(f, size, pass.Kind == PassSecureDiscard, ctl, progress)
+	case PassCryptoErase:
+		return cryptoErasePass(f, size, ctl, progress)
+	}
+	fill, err := pass.generator()
+	if err != nil {
+		return 0, err
+	}
+	var written uint64
+	for written < size {
+		if err := ctl.checkpoint(); err != nil {
+			return written, err
+		}
+		chunk := buf
+		if remaining := size - written; remaining < uint64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if err := fill(chunk, written); err != nil {
+			return written, err
+		}
+		n, err := f.WriteAt(chunk, int64(written))
+		written += uint64(n)
+		if err != nil {
+			return written, fmt.Errorf("write at offset %d: %w", written, err)
+		}
+		progress(written)
+	}
+	return written, nil
+}
+
+func regularFileSize(f *os.File) (uint64, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if info.Mode().IsRegular() {
+		return uint64(info.Size()), true, nil
+	}
+	return 0, false, nil
+}
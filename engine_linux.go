@@ -0,0 +1,71 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jaypipes/ghw"
+	"golang.org/x/sys/unix"
+)
+
+func diskTarget(d *ghw.Disk) WipeTarget {
+	path := "/dev/" + d.Name
+	t := WipeTarget{Name: d.Model, Path: path, Disk: path, ID: diskID(d, path), Size: d.SizeBytes, Rotational: d.DriveType == ghw.DriveTypeHDD}
+	for _, p := range d.Partitions {
+		t.Parts = append(t.Parts, partitionTarget(p))
+	}
+	return t
+}
+
+func partitionTarget(p *ghw.Partition) WipeTarget {
+	path := "/dev/" + p.Name
+	t := WipeTarget{Name: p.Name, Path: path, ID: partitionID(p, path), Size: p.SizeBytes,
+		FSType: ghwValue(p.Type), Label: ghwValue(p.FilesystemLabel)}
+	if p.Disk != nil {
+		t.Disk = "/dev/" + p.Disk.Name
+		t.Rotational = p.Disk.DriveType == ghw.DriveTypeHDD
+	}
+	return t
+}
+
+func openDevice(path string) (*os.File, uint64, error) {
+	flags := os.O_RDWR
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0 {
+		// The kernel refuses an exclusive open while the device is mounted
+		// or claimed by anything else, and refuses such claims while we
+		// hold it.
+		flags |= unix.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0)
+	if errors.Is(err, unix.EBUSY) {
+		return nil, 0, fmt.Errorf("%s: %w: %w", path, errHeld, err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	size, regular, err := regularFileSize(f)
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	if !regular {
+		// Seeking to the end of a block device yields its size in bytes.
+		end, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			f.Close()
+			return nil, 0, fmt.Errorf("%s: %w", path, err)
+		}
+		size = uint64(end)
+	}
+	return f, size, nil
+}
+
+// dropCache evicts the target from the page cache so verification reads
+// come from the device rather than from what was just written.
+func dropCache(f *os.File) error {
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
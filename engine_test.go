@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWipeDeviceRegularFile(t *testing.T) {
+	// Not a multiple of the chunk size, nor of a sector.
+	const size = 2*wipeChunkSize + 4097
+	path := filepath.Join(t.TempDir(), "disk.img")
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := []byte{0xA5, 0x5A, 0x3C}
+	method := &WipeMethod{
+		ID:     "test",
+		Name:   "Test",
+		Passes: []Pass{randPass, patternPass(pattern...)},
+		Verify: true,
+	}
+	job := WipeJob{Target: WipeTarget{Name: "disk.img", Path: path, Size: size}, Method: method}
+	var last WipeProgress
+	result := WipeDevice(job, newJobControl().ctl(), func(p WipeProgress) { last = p })
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if want := uint64(size * len(method.Passes)); result.BytesWritten != want {
+		t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, want)
+	}
+	if result.Target.Size != size {
+		t.Errorf("Target.Size = %d, want %d", result.Target.Size, size)
+	}
+	if last.Done != last.Total {
+		t.Errorf("progress ended at %d of %d bytes", last.Done, last.Total)
+	}
+	if len(result.Verifications) != 1 || result.Verifications[0].Pass != 2 {
+		t.Errorf("verifications = %+v, want one of pass 2", result.Verifications)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != size {
+		t.Fatalf("file is %d bytes after the wipe, want %d", len(got), size)
+	}
+	if want := repeatPattern(pattern, 0, size); !bytes.Equal(got, want) {
+		i := 0
+		for got[i] == want[i] {
+			i++
+		}
+		t.Fatalf("byte %d is 0x%02X, want 0x%02X", i, got[i], want[i])
+	}
+}
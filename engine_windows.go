@@ -0,0 +1,104 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/jaypipes/ghw"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlLockVolume        = 0x00090018
+	fsctlDismountVolume    = 0x00090020
+	ioctlDiskGetLengthInfo = 0x0007405C
+)
+
+func diskTarget(d *ghw.Disk) WipeTarget {
+	t := WipeTarget{Name: d.Model, Path: d.Name, Disk: d.Name, ID: diskID(d, d.Name), Size: d.SizeBytes, Rotational: d.DriveType == ghw.DriveTypeHDD}
+	for _, p := range d.Partitions {
+		// Only volumes with a drive letter can be opened on their own.
+		if p.MountPoint != "" {
+			t.Parts = append(t.Parts, partitionTarget(p))
+		}
+	}
+	return t
+}
+
+func partitionTarget(p *ghw.Partition) WipeTarget {
+	path := `\\.\` + p.MountPoint
+	t := WipeTarget{Name: p.Name, Path: path, ID: partitionID(p, path), Size: p.SizeBytes}
+	t.FSType, t.Label = volumeInformation(p.MountPoint + `\`)
+	if p.Disk != nil {
+		t.Disk = p.Disk.Name
+		t.Rotational = p.Disk.DriveType == ghw.DriveTypeHDD
+	}
+	return t
+}
+
+// volumeInformation returns the filesystem name and label of the volume
+// mounted at root, such as C:\.
+func volumeInformation(root string) (string, string) {
+	label := make([]uint16, windows.MAX_PATH+1)
+	fs := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(windows.StringToUTF16Ptr(root), &label[0], uint32(len(label)), nil, nil, nil, &fs[0], uint32(len(fs))); err != nil {
+		return "", ""
+	}
+	return windows.UTF16ToString(fs), windows.UTF16ToString(label)
+}
+
+func openDevice(path string) (*os.File, uint64, error) {
+	if !strings.HasPrefix(path, `\\.\`) {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		size, _, err := regularFileSize(f)
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, size, nil
+	}
+
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		windows.GENERIC_READ|windows.GENERIC_WRITE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_WRITE_THROUGH,
+		0,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	var returned uint32
+	if !strings.HasPrefix(strings.ToUpper(path), `\\.\PHYSICALDRIVE`) {
+		// Volumes must be locked and dismounted before Windows allows raw writes.
+		if err := windows.DeviceIoControl(handle, fsctlLockVolume, nil, 0, nil, 0, &returned, nil); err != nil {
+			windows.CloseHandle(handle)
+			return nil, 0, fmt.Errorf("%s: lock volume: %w", path, err)
+		}
+		if err := windows.DeviceIoControl(handle, fsctlDismountVolume, nil, 0, nil, 0, &returned, nil); err != nil {
+			windows.CloseHandle(handle)
+			return nil, 0, fmt.Errorf("%s: dismount volume: %w", path, err)
+		}
+	}
+	var length int64
+	if err := windows.DeviceIoControl(handle, ioctlDiskGetLengthInfo, nil, 0, (*byte)(unsafe.Pointer(&length)), uint32(unsafe.Sizeof(length)), &returned, nil); err != nil {
+		windows.CloseHandle(handle)
+		return nil, 0, fmt.Errorf("%s: get length: %w", path, err)
+	}
+	return os.NewFile(uintptr(handle), path), uint64(length), nil
+}
+
+// dropCache is a no-op: raw devices are opened write-through and Windows
+// does not cache reads of physical drives and locked volumes.
+func dropCache(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Fill files stay below FAT32's 4 GiB file size limit.
+	freeSpaceFileSize = 1 << 30
+	inodeFileNameLen  = 200
+	// Filesystems with dynamic inodes (XFS, Btrfs) report huge free counts;
+	// the inode stage stops here and says so in its result.
+	maxInodeFiles = 100000
+)
+
+// fsStats are the Statfs (or GetDiskFreeSpaceEx) numbers of a mounted
+// filesystem. FreeInodes is 0 where the filesystem has no inode table.
+type fsStats struct {
+	TotalBytes uint64
+	FreeBytes  uint64
+	FreeInodes uint64
+}
+
+type FreeSpaceResult struct {
+	Mount        string
+	Method       *WipeMethod
+	BytesWritten uint64
+	// BytesVerified were read back from the fill files of the passes the
+	// method verifies.
+	BytesVerified uint64
+	Files         int
+	InodeFiles    int
+	// FreeInodes is what the filesystem reported before the wipe. Past
+	// maxInodeFiles the rest are left and InodesTruncated is set.
+	FreeInodes      uint64
+	InodesTruncated bool
+	Err             error
+}
+
+type FreeSpaceProgress struct {
+	Pass      int
+	Passes    int
+	Verifying bool
+	Inodes    bool
+	Done      uint64
+	Total     uint64
+}
+
+// fillFile is a file written by a pass, from offset off of the pass's
+// stream on, so no two fill files hold the same bytes.
+type fillFile struct {
+	path string
+	off  uint64
+	size uint64
+}
+
+// WipeFreeSpace fills the free space of the filesystem mounted at mount with
+// the method's passes, then creates empty files with long names to use up
+// free inodes and stale directory entries. Each pass writes new fill files
+// in place of the last pass's, since rewriting a file in place allocates new
+// blocks on copy-on-write filesystems and would run out of space. Passes the
+// method verifies are read back from the fill files, verifyPct percent of
+// them. Everything Wipr creates lives in one hidden directory that is
+// removed again, even on error or cancel.
+func WipeFreeSpace(mount string, method *WipeMethod, verifyPct int, ctl wipeControl, progress func(FreeSpaceProgress)) (result FreeSpaceResult) {
+	result.Mount = mount
+	result.Method = method
+	if err := overwriteOnly(method); err != nil {
+		result.Err = err
+		return
+	}
+	passes, verified, err := preparePasses(method)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	percent := verifyPercent(verifyPct)
+	if err := checkMountPoint(mount); err != nil {
+		result.Err = err
+		return
+	}
+	stats, err := fsUsage(mount)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	inodes := min(stats.FreeInodes, maxInodeFiles)
+	result.FreeInodes = stats.FreeInodes
+	var total uint64
+	for i := range passes {
+		total += stats.FreeBytes
+		if verified[i] {
+			total += stats.FreeBytes * uint64(percent) / 100
+		}
+	}
+	fillTotal := total
+	total += inodes
+	report := func(pass int, verifying, inodeStage bool, done uint64) {
+		if progress != nil {
+			progress(FreeSpaceProgress{Pass: pass, Passes: len(passes), Verifying: verifying, Inodes: inodeStage, Done: done, Total: total})
+		}
+	}
+
+	name, err := randomName(12)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	dir := filepath.Join(mount, ".wipr-freespace-"+name)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		result.Err = err
+		return
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil && result.Err == nil {
+			result.Err = fmt.Errorf("cleanup: %w", err)
+		}
+		syncDir(mount)
+	}()
+
+	var done uint64
+	var files []fillFile
+	for i, pass := range passes {
+		if err := removeFillFiles(dir, files); err != nil {
+			result.Err = err
+			return
+		}
+		start := done
+		var written uint64
+		files, written, err = fillFreeSpace(dir, pass, freeSpaceFileSize, ctl, func(n uint64) { report(i+1, false, false, start+n) })
+		done += written
+		result.BytesWritten += written
+		result.Files = max(result.Files, len(files))
+		if err != nil {
+			result.Err = fmt.Errorf("pass %d (%s): %w", i+1, pass, err)
+			return
+		}
+		if !verified[i] {
+			continue
+		}
+		start = done
+		checked, err := verifyFillFiles(files, pass, percent, ctl, func(n uint64) { report(i+1, true, false, start+n) })
+		done += checked
+		result.BytesVerified += checked
+		if err != nil {
+			result.Err = fmt.Errorf("pass %d (%s): verify: %w", i+1, pass, err)
+			return
+		}
+	}
+	if err := removeFillFiles(dir, files); err != nil {
+		result.Err = err
+		return
+	}
+
+	result.InodeFiles, result.Err = exhaustInodes(dir, inodes, ctl, func(n uint64) {
+		report(len(passes), false, true, fillTotal+n)
+	})
+	result.InodesTruncated = result.Err == nil && uint64(result.InodeFiles) == inodes && inodes < stats.FreeInodes
+	return
+}
+
+// removeFillFiles deletes the fill files of a pass and makes sure the
+// filesystem has their space back before the next one is written.
+func removeFillFiles(dir string, files []fillFile) error {
+	for _, ff := range files {
+		if err := os.Remove(ff.path); err != nil {
+			return err
+		}
+	}
+	syncDir(dir)
+	return nil
+}
+
+// verifyFillFiles reads the fill files back and compares them with what
+// pass wrote into each of them.
+func verifyFillFiles(files []fillFile, pass Pass, percent int, ctl wipeControl, progress func(uint64)) (uint64, error) {
+	fill, err := pass.generator()
+	if err != nil {
+		return 0, err
+	}
+	var checked uint64
+	for _, ff := range files {
+		f, err := os.Open(ff.path)
+		if err != nil {
+			return checked, err
+		}
+		if err := dropCache(f); err != nil {
+			f.Close()
+			return checked, fmt.Errorf("%s: drop cache: %w", ff.path, err)
+		}
+		start := checked
+		expected := func(buf []byte, off uint64) error { return fill(buf, ff.off+off) }
+		vr, err := verifyFill(f, ff.size, expected, percent, ctl, func(n uint64) { progress(start + n) })
+		f.Close()
+		checked += vr.BytesChecked
+		if err == nil && len(vr.Mismatches) > 0 {
+			err = fmt.Errorf("%w: %s", errVerifyFailed, vr)
+		}
+		if err != nil {
+			return checked, fmt.Errorf("%s: %w", ff.path, err)
+		}
+	}
+	return checked, nil
+}
+
+func overwriteOnly(method *WipeMethod) error {
+	if len(method.Passes) == 0 {
+		return fmt.Errorf("%s only applies to devices", method.Name)
+	}
+	for _, pass := range method.Passes {
+		switch pass.Kind {
+		case PassDiscard, PassSecureDiscard, PassCryptoErase:
+			return fmt.Errorf("%s cannot be used on files", method.Name)
+		}
+	}
+	return nil
+}
+
+// fillFreeSpace writes fill files of up to fileSize bytes into dir until
+// the filesystem is full. The pass's stream runs on from one file into the
+// next.
+func fillFreeSpace(dir string, pass Pass, fileSize uint64, ctl wipeControl, progress func(uint64)) ([]fillFile, uint64, error) {
+	fill, err := pass.generator()
+	if err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, wipeChunkSize)
+	var files []fillFile
+	var written uint64
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("fill-%06d", i))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if isNoSpace(err) {
+			return files, written, nil
+		}
+		if err != nil {
+			return files, written, err
+		}
+		off := written
+		var size uint64
+		full := false
+		for size < fileSize {
+			if err = ctl.checkpoint(); err != nil {
+				break
+			}
+			if err = fill(buf, written); err != nil {
+				break
+			}
+			var n int
+			n, err = f.Write(buf)
+			size += uint64(n)
+			written += uint64(n)
+			progress(written)
+			if isNoSpace(err) {
+				full, err = true, nil
+				break
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = f.Sync()
+			if isNoSpace(err) {
+				full, err = true, nil
+			}
+		}
+		f.Close()
+		files = append(files, fillFile{path: path, off: off, size: size})
+		if err != nil {
+			return files, written, err
+		}
+		if full {
+			return files, written, nil
+		}
+	}
+}
+
+// exhaustInodes creates up to count empty files with long names in dir, so
+// that free inodes and directory slots get overwritten, then removes them.
+func exhaustInodes(dir string, count uint64, ctl wipeControl, progress func(uint64)) (int, error) {
+	sub := filepath.Join(dir, "inodes")
+	if err := os.Mkdir(sub, 0700); err != nil {
+		if isNoSpace(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var created uint64
+	var err error
+	for ; created < count; created++ {
+		if err = ctl.checkpoint(); err != nil {
+			break
+		}
+		var name string
+		if name, err = randomName(inodeFileNameLen); err != nil {
+			break
+		}
+		var f *os.File
+		f, err = os.OpenFile(filepath.Join(sub, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if isNoSpace(err) {
+			err = nil
+			break
+		}
+		if errors.Is(err, os.ErrExist) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			break
+		}
+		f.Close()
+		if created%256 == 0 {
+			progress(created)
+		}
+	}
+	progress(created)
+	syncDir(sub)
+	if rmErr := os.RemoveAll(sub); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	return int(created), err
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func fsUsage(mount string) (fsStats, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(mount, &stat); err != nil {
+		return fsStats{}, err
+	}
+	// Wipr runs as root, so the blocks reserved for root are free to it too.
+	return fsStats{
+		TotalBytes: stat.Blocks * uint64(stat.Bsize),
+		FreeBytes:  stat.Bfree * uint64(stat.Bsize),
+		FreeInodes: stat.Ffree,
+	}, nil
+}
+
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
@@ -0,0 +1,151 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// tmpfsMount mounts a small tmpfs for the free-space tests, which need a
+// filesystem they can fill up.
+func tmpfsMount(t *testing.T, options string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := unix.Mount("tmpfs", dir, "tmpfs", 0, options); err != nil {
+		t.Skipf("cannot mount a tmpfs: %v", err)
+	}
+	t.Cleanup(func() { unix.Unmount(dir, 0) })
+	return dir
+}
+
+func TestFillFreeSpace(t *testing.T) {
+	mount := tmpfsMount(t, "size=8m")
+	pass := Pass{Kind: PassRandom, Seed: "fill test"}
+	files, written, err := fillFreeSpace(mount, pass, 3*wipeChunkSize, newJobControl().ctl(), func(uint64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("filled the free space with %d files, want several", len(files))
+	}
+	stats, err := fsUsage(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FreeBytes >= wipeChunkSize {
+		t.Errorf("%d bytes left free", stats.FreeBytes)
+	}
+
+	// The files hold the pass's stream from start to end, not the same
+	// start of it over and over.
+	want := make([]byte, written)
+	newKeystream(pass.Seed).ReadAt(want, 0)
+	var got []byte
+	for i, ff := range files {
+		b, err := os.ReadFile(ff.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(b)) != ff.size || ff.off != uint64(len(got)) {
+			t.Errorf("file %d: %d bytes at %d, recorded as %d at %d", i, len(b), len(got), ff.size, ff.off)
+		}
+		got = append(got, b...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("fill files do not hold the pass's stream")
+	}
+
+	checked, err := verifyFillFiles(files, pass, 100, newJobControl().ctl(), func(uint64) {})
+	if err != nil || checked != written {
+		t.Errorf("verified %d of %d bytes: %v", checked, written, err)
+	}
+	last := files[len(files)-1]
+	f, err := os.OpenFile(last.path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{^got[last.off]}, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	_, err = verifyFillFiles(files, pass, 100, newJobControl().ctl(), func(uint64) {})
+	if !errors.Is(err, errVerifyFailed) || !strings.Contains(err.Error(), last.path) || !strings.Contains(err.Error(), "at bytes 0-0") {
+		t.Errorf("corrupt fill file: got %v", err)
+	}
+
+	if err := removeFillFiles(mount, files); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(mount); len(entries) != 0 {
+		t.Errorf("%d entries left", len(entries))
+	}
+	if after, _ := fsUsage(mount); after.FreeBytes < 7<<20 {
+		t.Errorf("only %d bytes free after removing the fill files", after.FreeBytes)
+	}
+}
+
+func TestWipeFreeSpace(t *testing.T) {
+	mount := tmpfsMount(t, "size=8m,nr_inodes=500")
+	keep := filepath.Join(mount, "keep.txt")
+	if err := os.WriteFile(keep, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before, err := fsUsage(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last FreeSpaceProgress
+	result := WipeFreeSpace(mount, MethodByID("dod-3"), 100, newJobControl().ctl(), func(p FreeSpaceProgress) { last = p })
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	// Every pass fills the whole free space again, and the verified last
+	// pass is read back in full.
+	if result.BytesWritten < 3*(before.FreeBytes-wipeChunkSize) || result.BytesWritten > 3*before.FreeBytes {
+		t.Errorf("wrote %d bytes over 3 passes of %d free", result.BytesWritten, before.FreeBytes)
+	}
+	if result.BytesVerified != result.BytesWritten/3 {
+		t.Errorf("verified %d bytes, want %d", result.BytesVerified, result.BytesWritten/3)
+	}
+	if result.InodeFiles == 0 || result.InodesTruncated || result.FreeInodes != before.FreeInodes {
+		t.Errorf("inode stage: %d files of %d free, truncated %v", result.InodeFiles, result.FreeInodes, result.InodesTruncated)
+	}
+	if !last.Inodes || last.Pass != 3 {
+		t.Errorf("last progress %+v", last)
+	}
+
+	entries, err := os.ReadDir(mount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep.txt" {
+		t.Errorf("left behind %v", entries)
+	}
+	if b, err := os.ReadFile(keep); err != nil || string(b) != "keep me" {
+		t.Errorf("existing file changed: %q, %v", b, err)
+	}
+	if after, _ := fsUsage(mount); after.FreeBytes != before.FreeBytes {
+		t.Errorf("%d bytes free after the wipe, %d before", after.FreeBytes, before.FreeBytes)
+	}
+}
+
+func TestWipeFreeSpaceCancel(t *testing.T) {
+	mount := tmpfsMount(t, "size=8m")
+	job := newJobControl()
+	job.Cancel()
+	result := WipeFreeSpace(mount, MethodByID("zero"), 100, job.ctl(), nil)
+	if !errors.Is(result.Err, errCancelled) {
+		t.Errorf("got %v, want %v", result.Err, errCancelled)
+	}
+	if entries, _ := os.ReadDir(mount); len(entries) != 0 {
+		t.Errorf("left behind %v", entries)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+func fsUsage(mount string) (fsStats, error) {
+	var freeBytesAvailable, totalNumberOfBytes, totalNumberOfFreeBytes uint64
+	err := windows.GetDiskFreeSpaceEx(
+		windows.StringToUTF16Ptr(mount),
+		&freeBytesAvailable,
+		&totalNumberOfBytes,
+		&totalNumberOfFreeBytes,
+	)
+	if err != nil {
+		return fsStats{}, err
+	}
+	// NTFS and FAT have no fixed inode table; the MFT grows as files are added.
+	return fsStats{TotalBytes: totalNumberOfBytes, FreeBytes: totalNumberOfFreeBytes}, nil
+}
+
+func isNoSpace(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
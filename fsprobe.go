@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxDirectoryRead bounds how much of a FAT or exFAT root directory is
+// searched for the volume label.
+const maxDirectoryRead = 64 << 10
+
+// FSInfo is what a partition's superblock says about the filesystem, or
+// the LUKS or LVM container, on it. Type uses the names blkid and udev use,
+// such as ext4, vfat or crypto_LUKS; Version tells FAT12, FAT16 and FAT32,
+// and LUKS1 and LUKS2, apart.
+type FSInfo struct {
+	Type    string
+	Version string
+	Label   string
+	UUID    string
+}
+
+// fsTypeName is how a filesystem type is shown.
+func fsTypeName(typ, version string) string {
+	switch typ {
+	case "crypto_LUKS":
+		return "LUKS" + version
+	case "LVM2_member":
+		return "LVM PV"
+	case "vfat":
+		return ternary(version != "", version, "FAT")
+	}
+	return typ
+}
+
+func (fs FSInfo) String() string {
+	s := fsTypeName(fs.Type, fs.Version)
+	if fs.Label != "" {
+		s += " \"" + fs.Label + "\""
+	}
+	return s
+}
+
+// filesystem describes what was found on a target, for lists and reports.
+func (t WipeTarget) filesystem() string {
+	if t.FSType == "" {
+		return ""
+	}
+	s := FSInfo{Type: t.FSType, Version: t.FSVersion, Label: t.Label}.String()
+	if t.FSUUID != "" {
+		s += ", UUID " + t.FSUUID
+	}
+	return s
+}
+
+// withFilesystem fills in the filesystem of a target from what its
+// superblock says, keeping what the system reported where nothing was
+// found.
+func (t WipeTarget) withFilesystem(fs FSInfo) WipeTarget {
+	if fs.Type != "" {
+		t.FSType, t.FSVersion, t.Label, t.FSUUID = fs.Type, fs.Version, fs.Label, fs.UUID
+	}
+	return t
+}
+
+var filesystemProbes = []func(*signatureProbe) (FSInfo, error){
+	probeLUKSInfo,
+	probeLVMInfo,
+	probeExtInfo,
+	probeXFSInfo,
+	probeBtrfsInfo,
+	probeNTFSInfo,
+	probeExFATInfo,
+	probeFATInfo,
+	probeSwapInfo,
+}
+
+// readFilesystem identifies the filesystem on r, which is size bytes long,
+// and reads its label and UUID. It returns an empty FSInfo when none of the
+// known filesystems is found.
+func readFilesystem(r io.ReaderAt, size uint64) (FSInfo, error) {
+	p := &signatureProbe{r: r, size: size}
+	for _, probe := range filesystemProbes {
+		fs, err := probe(p)
+		if err != nil || fs.Type != "" {
+			return fs, err
+		}
+	}
+	return FSInfo{}, nil
+}
+
+// probeFilesystem reads the superblock of a target without opening it for
+// writing.
+func probeFilesystem(t WipeTarget) (FSInfo, error) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return FSInfo{}, err
+	}
+	defer f.Close()
+	size, regular, err := regularFileSize(f)
+	if err != nil {
+		return FSInfo{}, err
+	}
+	var r io.ReaderAt = f
+	switch {
+	case t.Offset > 0:
+		r = io.NewSectionReader(f, int64(t.Offset), int64(t.Size))
+		size = t.Size
+	case !regular:
+		size = t.Size
+	}
+	fs, err := readFilesystem(r, size)
+	if err != nil {
+		return fs, fmt.Errorf("%s: %w", t.Path, err)
+	}
+	return fs, nil
+}
+
+// fsLabel decodes a fixed-size, NUL or space padded label.
+func fsLabel(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(b), ""))
+}
+
+func utf16Label(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+}
+
+// uuidString formats a 16-byte UUID, or returns "" for the nil UUID.
+func uuidString(b []byte) string {
+	if bytes.Equal(b, make([]byte, 16)) {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// serialString formats the 32-bit volume serial of FAT and exFAT.
+func serialString(serial uint32) string {
+	return fmt.Sprintf("%04X-%04X", serial>>16, serial&0xFFFF)
+}
+
+func probeLUKSInfo(p *signatureProbe) (FSInfo, error) {
+	hdr, err := p.read(0, 208)
+	if hdr == nil || err != nil || !bytes.HasPrefix(hdr, luksMagic) {
+		return FSInfo{}, err
+	}
+	version := binary.BigEndian.Uint16(hdr[6:8])
+	fs := FSInfo{Type: "crypto_LUKS", Version: fmt.Sprint(version), UUID: fsLabel(hdr[168:208])}
+	if version == 2 {
+		fs.Label = fsLabel(hdr[24:72])
+	}
+	return fs, nil
+}
+
+func probeLVMInfo(p *signatureProbe) (FSInfo, error) {
+	for sector := uint64(0); sector < 4; sector++ {
+		label, err := p.read(sector*512, 512)
+		if label == nil || err != nil {
+			return FSInfo{}, err
+		}
+		if !bytes.Equal(label[:8], []byte("LABELONE")) || !bytes.Equal(label[24:32], []byte("LVM2 001")) {
+			continue
+		}
+		fs := FSInfo{Type: "LVM2_member"}
+		// The PV header, which starts with the PV UUID, follows the label.
+		if off := binary.LittleEndian.Uint32(label[20:24]); off >= 32 && off <= 512-32 {
+			id := string(label[off : off+32])
+			fs.UUID = strings.Join([]string{id[0:6], id[6:10], id[10:14], id[14:18], id[18:22], id[22:26], id[26:32]}, "-")
+		}
+		return fs, nil
+	}
+	return FSInfo{}, nil
+}
+
+func probeExtInfo(p *signatureProbe) (FSInfo, error) {
+	sb, err := p.read(1024, 0x88)
+	if sb == nil || err != nil || !isExtSuperblock(sb) {
+		return FSInfo{}, err
+	}
+	return FSInfo{Type: extVariant(sb), UUID: uuidString(sb[0x68:0x78]), Label: fsLabel(sb[0x78:0x88])}, nil
+}
+
+func probeXFSInfo(p *signatureProbe) (FSInfo, error) {
+	sb, err := p.read(0, 120)
+	if sb == nil || err != nil || !bytes.Equal(sb[:4], []byte("XFSB")) {
+		return FSInfo{}, err
+	}
+	return FSInfo{Type: "xfs", UUID: uuidString(sb[32:48]), Label: fsLabel(sb[108:120])}, nil
+}
+
+func probeBtrfsInfo(p *signatureProbe) (FSInfo, error) {
+	sb, err := p.read(64<<10, 0x12B+256)
+	if sb == nil || err != nil || !bytes.Equal(sb[0x40:0x48], []byte("_BHRfS_M")) {
+		return FSInfo{}, err
+	}
+	return FSInfo{Type: "btrfs", UUID: uuidString(sb[0x20:0x30]), Label: fsLabel(sb[0x12B:])}, nil
+}
+
+// probeNTFSInfo reads the volume serial from the boot sector and the label
+// from the $Volume record of the MFT.
+func probeNTFSInfo(p *signatureProbe) (FSInfo, error) {
+	b, err := p.read(0, 512)
+	if b == nil || err != nil || !bytes.Equal(b[3:11], []byte("NTFS    ")) {
+		return FSInfo{}, err
+	}
+	fs := FSInfo{Type: "ntfs", UUID: fmt.Sprintf("%016X", binary.LittleEndian.Uint64(b[0x48:]))}
+	bps := uint64(binary.LittleEndian.Uint16(b[0x0B:]))
+	spc := uint64(b[0x0D])
+	if spc > 0x80 {
+		spc = 1 << (256 - spc)
+	}
+	cluster := bps * spc
+	// A negative record size is a power of two; anything past 2^31 is a
+	// damaged boot sector.
+	recordSize := uint64(int8(b[0x40])) * cluster
+	if v := int8(b[0x40]); v < 0 {
+		shift := uint(-int(v))
+		if shift > 31 {
+			return fs, nil
+		}
+		recordSize = 1 << shift
+	}
+	if bps < 256 || cluster == 0 || recordSize < bps || recordSize > 64<<10 {
+		return fs, nil
+	}
+	// $Volume is the fourth record of the MFT.
+	rec, err := p.read(binary.LittleEndian.Uint64(b[0x30:])*cluster+3*recordSize, int(recordSize))
+	if rec == nil || err != nil || !bytes.Equal(rec[:4], []byte("FILE")) {
+		return fs, err
+	}
+	// Undo the update sequence, which replaces the last two bytes of every
+	// sector of the record.
+	usa := int(binary.LittleEndian.Uint16(rec[4:]))
+	usaCount := int(binary.LittleEndian.Uint16(rec[6:]))
+	if usa+2*usaCount > len(rec) || uint64(usaCount-1)*bps > recordSize {
+		return fs, nil
+	}
+	for i := 1; i < usaCount; i++ {
+		end := uint64(i) * bps
+		copy(rec[end-2:end], rec[usa+2*i:usa+2*i+2])
+	}
+	off := int(binary.LittleEndian.Uint16(rec[0x14:]))
+	for off+0x18 <= len(rec) {
+		typ := binary.LittleEndian.Uint32(rec[off:])
+		length := int(binary.LittleEndian.Uint32(rec[off+4:]))
+		if typ == 0xFFFFFFFF || length < 0x18 || off+length > len(rec) {
+			break
+		}
+		// A resident $VOLUME_NAME attribute.
+		if typ == 0x60 && rec[off+8] == 0 {
+			size := int(binary.LittleEndian.Uint32(rec[off+0x10:]))
+			start := off + int(binary.LittleEndian.Uint16(rec[off+0x14:]))
+			if start+size <= off+length {
+				fs.Label = utf16Label(rec[start : start+size])
+			}
+			break
+		}
+		off += length
+	}
+	return fs, nil
+}
+
+func probeExFATInfo(p *signatureProbe) (FSInfo, error) {
+	b, err := p.read(0, 512)
+	if b == nil || err != nil || !bytes.Equal(b[3:11], []byte("EXFAT   ")) {
+		return FSInfo{}, err
+	}
+	fs := FSInfo{Type: "exfat", UUID: serialString(binary.LittleEndian.Uint32(b[0x64:]))}
+	sectorShift, clusterShift := uint64(b[0x6C]), uint64(b[0x6D])
+	rootCluster := uint64(binary.LittleEndian.Uint32(b[0x60:]))
+	if sectorShift < 9 || sectorShift > 12 || sectorShift+clusterShift > 25 || rootCluster < 2 {
+		return fs, nil
+	}
+	cluster := uint64(1) << (sectorShift + clusterShift)
+	root := uint64(binary.LittleEndian.Uint32(b[0x58:]))<<sectorShift + (rootCluster-2)*cluster
+	dir, err := p.read(root, int(min(cluster, maxDirectoryRead)))
+	if dir == nil || err != nil {
+		return fs, err
+	}
+	for off := 0; off+32 <= len(dir) && dir[off] != 0x00; off += 32 {
+		// The volume label entry.
+		if dir[off] == 0x83 {
+			n := min(int(dir[off+1]), 11)
+			fs.Label = utf16Label(dir[off+2 : off+2+2*n])
+			break
+		}
+	}
+	return fs, nil
+}
+
+// probeFATInfo tells FAT12, FAT16 and FAT32 apart by their cluster count,
+// as the specification does, and prefers the volume label in the root
+// directory to the one in the boot sector, as Windows does.
+func probeFATInfo(p *signatureProbe) (FSInfo, error) {
+	b, err := p.read(0, 512)
+	if b == nil || err != nil || !isFATBootSector(b) {
+		return FSInfo{}, err
+	}
+	bps := uint64(binary.LittleEndian.Uint16(b[0x0B:]))
+	spc := uint64(b[0x0D])
+	reserved := uint64(binary.LittleEndian.Uint16(b[0x0E:]))
+	fats := uint64(b[0x10])
+	rootEntries := uint64(binary.LittleEndian.Uint16(b[0x11:]))
+	total := uint64(binary.LittleEndian.Uint16(b[0x13:]))
+	if total == 0 {
+		total = uint64(binary.LittleEndian.Uint32(b[0x20:]))
+	}
+	fatSize := uint64(binary.LittleEndian.Uint16(b[0x16:]))
+	fat32 := fatSize == 0
+	if fat32 {
+		fatSize = uint64(binary.LittleEndian.Uint32(b[0x24:]))
+	}
+	if bps == 0 || spc == 0 {
+		return FSInfo{Type: "vfat"}, nil
+	}
+	rootSectors := (rootEntries*32 + bps - 1) / bps
+	firstData := reserved + fats*fatSize + rootSectors
+	if firstData > total {
+		return FSInfo{Type: "vfat"}, nil
+	}
+	fs := FSInfo{Type: "vfat", Version: "FAT32"}
+	switch clusters := (total - firstData) / spc; {
+	case clusters < 4085:
+		fs.Version = "FAT12"
+	case clusters < 65525:
+		fs.Version = "FAT16"
+	}
+	// The extended boot record sits further down on FAT32.
+	ebr := 0x24
+	root, rootSize := (reserved+fats*fatSize)*bps, rootSectors*bps
+	if fat32 {
+		ebr = 0x40
+		root = (firstData + (uint64(binary.LittleEndian.Uint32(b[0x2C:]))-2)*spc) * bps
+		rootSize = spc * bps
+	}
+	if b[ebr+2] == 0x29 {
+		fs.UUID = serialString(binary.LittleEndian.Uint32(b[ebr+3:]))
+		if label := fsLabel(b[ebr+7 : ebr+18]); label != "NO NAME" {
+			fs.Label = label
+		}
+	}
+	dir, err := p.read(root, int(min(rootSize, maxDirectoryRead)))
+	if dir == nil || err != nil {
+		return fs, err
+	}
+	for off := 0; off+32 <= len(dir) && dir[off] != 0x00; off += 32 {
+		attr := dir[off+11]
+		// Skip deleted entries and long file names, and find the one
+		// that holds the volume label.
+		if dir[off] != 0xE5 && attr != 0x0F && attr&0x08 != 0 && attr&0x10 == 0 {
+			fs.Label = fsLabel(dir[off : off+11])
+			break
+		}
+	}
+	return fs, nil
+}
+
+func probeSwapInfo(p *signatureProbe) (FSInfo, error) {
+	for _, page := range []uint64{4096, 8192, 16384, 65536} {
+		magic, err := p.read(page-10, 10)
+		if magic == nil || err != nil {
+			return FSInfo{}, err
+		}
+		if string(magic) != "SWAPSPACE2" && string(magic) != "SWAP-SPACE" {
+			continue
+		}
+		fs := FSInfo{Type: "swap"}
+		// Version 1 headers carry a UUID and label after the boot block.
+		if hdr, err := p.read(1024, 44); err != nil {
+			return fs, err
+		} else if hdr != nil && string(magic) == "SWAPSPACE2" {
+			fs.UUID, fs.Label = uuidString(hdr[12:28]), fsLabel(hdr[28:44])
+		}
+		return fs, nil
+	}
+	return FSInfo{}, nil
+}
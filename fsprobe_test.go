@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+var testUUID = []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+const testUUIDString = "12345678-9abc-def0-0123-456789abcdef"
+
+func putUTF16(b []byte, s string) int {
+	for i, u := range utf16.Encode([]rune(s)) {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return 2 * len(s)
+}
+
+func luksImage(version uint16) []byte {
+	img := make([]byte, 64<<10)
+	copy(img, luksMagic)
+	binary.BigEndian.PutUint16(img[6:], version)
+	copy(img[168:], testUUIDString)
+	if version == 2 {
+		copy(img[24:], "secret")
+	}
+	return img
+}
+
+func lvmImage() []byte {
+	img := make([]byte, 64<<10)
+	label := img[512:]
+	copy(label, "LABELONE")
+	binary.LittleEndian.PutUint32(label[20:], 32)
+	copy(label[24:], "LVM2 001")
+	copy(label[32:], "abcdefghijklmnopqrstuvwxyz012345")
+	return img
+}
+
+func extImage() []byte {
+	img := make([]byte, 64<<10)
+	sb := img[1024:]
+	copy(sb[0x38:], []byte{0x53, 0xEF})
+	binary.LittleEndian.PutUint32(sb[0x60:], 0x40)
+	copy(sb[0x68:], testUUID)
+	copy(sb[0x78:], "rootfs")
+	return img
+}
+
+func xfsImage() []byte {
+	img := make([]byte, 64<<10)
+	copy(img, "XFSB")
+	copy(img[32:], testUUID)
+	copy(img[108:], "data")
+	return img
+}
+
+func btrfsImage() []byte {
+	img := make([]byte, 128<<10)
+	sb := img[64<<10:]
+	copy(sb[0x20:], testUUID)
+	copy(sb[0x40:], "_BHRfS_M")
+	copy(sb[0x12B:], "pool")
+	return img
+}
+
+// ntfsImage has 4 KiB clusters, the MFT at cluster 4 and 1 KiB records,
+// with the update sequence applied to the $Volume record.
+func ntfsImage() []byte {
+	img := make([]byte, 64<<10)
+	copy(img[3:], "NTFS    ")
+	binary.LittleEndian.PutUint16(img[0x0B:], 512)
+	img[0x0D] = 8
+	binary.LittleEndian.PutUint64(img[0x30:], 4)
+	img[0x40] = 0xF6 // 2^10
+	binary.LittleEndian.PutUint64(img[0x48:], 0x0123456789ABCDEF)
+	copy(img[510:], bootSignature)
+
+	rec := img[4*4096+3*1024:][:1024]
+	copy(rec, "FILE")
+	binary.LittleEndian.PutUint16(rec[4:], 0x30)
+	binary.LittleEndian.PutUint16(rec[6:], 3)
+	binary.LittleEndian.PutUint16(rec[0x14:], 0x38)
+	attr := rec[0x38:]
+	binary.LittleEndian.PutUint32(attr, 0x60)
+	binary.LittleEndian.PutUint32(attr[4:], 0x28)
+	n := putUTF16(attr[0x18:], "Windows")
+	binary.LittleEndian.PutUint32(attr[0x10:], uint32(n))
+	binary.LittleEndian.PutUint16(attr[0x14:], 0x18)
+	binary.LittleEndian.PutUint32(rec[0x38+0x28:], 0xFFFFFFFF)
+	// The update sequence number goes at the end of each sector, and the
+	// bytes it replaced into the array.
+	copy(rec[0x30:], []byte{0x07, 0x00})
+	for i := 1; i <= 2; i++ {
+		end := i * 512
+		copy(rec[0x30+2*i:], rec[end-2:end])
+		copy(rec[end-2:], []byte{0x07, 0x00})
+	}
+	return img
+}
+
+func exfatImage() []byte {
+	img := make([]byte, 64<<10)
+	copy(img[3:], "EXFAT   ")
+	binary.LittleEndian.PutUint32(img[0x58:], 64)
+	binary.LittleEndian.PutUint32(img[0x60:], 2)
+	binary.LittleEndian.PutUint32(img[0x64:], 0xCAFEF00D)
+	img[0x6C], img[0x6D] = 9, 3
+	copy(img[510:], bootSignature)
+	dir := img[64*512:]
+	dir[0] = 0x83
+	dir[1] = byte(putUTF16(dir[2:], "USB") / 2)
+	return img
+}
+
+// fatImage is a FAT12 or FAT16 volume with 512-byte sectors, or a FAT32
+// one with its root directory at cluster 2.
+func fatImage(version string, totalSectors uint32) []byte {
+	img := make([]byte, 64<<10)
+	img[0] = 0xEB
+	binary.LittleEndian.PutUint16(img[0x0B:], 512)
+	img[0x0D] = 1
+	binary.LittleEndian.PutUint16(img[0x0E:], 1)
+	img[0x10] = 2
+	binary.LittleEndian.PutUint32(img[0x20:], totalSectors)
+	ebr, root := 0x24, 0
+	if version == "FAT32" {
+		binary.LittleEndian.PutUint32(img[0x24:], 8)
+		binary.LittleEndian.PutUint32(img[0x2C:], 2)
+		copy(img[0x52:], "FAT32   ")
+		ebr, root = 0x40, (1+2*8)*512
+	} else {
+		binary.LittleEndian.PutUint16(img[0x11:], 512)
+		binary.LittleEndian.PutUint16(img[0x16:], 8)
+		copy(img[0x36:], version+"   ")
+		root = (1 + 2*8) * 512
+	}
+	img[ebr+2] = 0x29
+	binary.LittleEndian.PutUint32(img[ebr+3:], 0x1234ABCD)
+	copy(img[ebr+7:], "BOOTLABEL  ")
+	copy(img[510:], bootSignature)
+	copy(img[root:], "ROOTLABEL  ")
+	img[root+11] = 0x08
+	return img
+}
+
+func swapImage() []byte {
+	img := make([]byte, 64<<10)
+	copy(img[1024+12:], testUUID)
+	copy(img[1024+28:], "swap0")
+	copy(img[4096-10:], "SWAPSPACE2")
+	return img
+}
+
+type fsCase struct {
+	name string
+	img  []byte
+	want FSInfo
+}
+
+func fsCases() []fsCase {
+	return []fsCase{
+		{"luks1", luksImage(1), FSInfo{Type: "crypto_LUKS", Version: "1", UUID: testUUIDString}},
+		{"luks2", luksImage(2), FSInfo{Type: "crypto_LUKS", Version: "2", Label: "secret", UUID: testUUIDString}},
+		{"lvm", lvmImage(), FSInfo{Type: "LVM2_member", UUID: "abcdef-ghij-klmn-opqr-stuv-wxyz-012345"}},
+		{"ext4", extImage(), FSInfo{Type: "ext4", Label: "rootfs", UUID: testUUIDString}},
+		{"xfs", xfsImage(), FSInfo{Type: "xfs", Label: "data", UUID: testUUIDString}},
+		{"btrfs", btrfsImage(), FSInfo{Type: "btrfs", Label: "pool", UUID: testUUIDString}},
+		{"ntfs", ntfsImage(), FSInfo{Type: "ntfs", Label: "Windows", UUID: "0123456789ABCDEF"}},
+		{"exfat", exfatImage(), FSInfo{Type: "exfat", Label: "USB", UUID: "CAFE-F00D"}},
+		{"fat12", fatImage("FAT12", 4000), FSInfo{Type: "vfat", Version: "FAT12", Label: "ROOTLABEL", UUID: "1234-ABCD"}},
+		{"fat16", fatImage("FAT16", 20000), FSInfo{Type: "vfat", Version: "FAT16", Label: "ROOTLABEL", UUID: "1234-ABCD"}},
+		{"fat32", fatImage("FAT32", 70000), FSInfo{Type: "vfat", Version: "FAT32", Label: "ROOTLABEL", UUID: "1234-ABCD"}},
+		{"swap", swapImage(), FSInfo{Type: "swap", Label: "swap0", UUID: testUUIDString}},
+	}
+}
+
+func TestReadFilesystem(t *testing.T) {
+	for _, tt := range fsCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFilesystem(bytes.NewReader(tt.img), uint64(len(tt.img)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if got, err := readFilesystem(bytes.NewReader(make([]byte, 64<<10)), 64<<10); err != nil || got != (FSInfo{}) {
+		t.Errorf("blank device: got %+v, %v", got, err)
+	}
+}
+
+// Every probe must cope with a device that ends anywhere in its headers.
+func TestReadFilesystemTruncated(t *testing.T) {
+	for _, tt := range fsCases() {
+		for _, n := range []int{0, 1, 8, 511, 512, 1023, 1024 + 0x3A, 1100, 4095, 4096, 16384 + 3072 + 100, 32768 + 16, 65536 + 0x48, len(tt.img) - 1} {
+			if n > len(tt.img) {
+				continue
+			}
+			if _, err := readFilesystem(bytes.NewReader(tt.img[:n]), uint64(n)); err != nil {
+				t.Errorf("%s cut to %d bytes: %v", tt.name, n, err)
+			}
+		}
+	}
+}
+
+// hostileValues are the byte values most likely to trip signed, shift and
+// length arithmetic.
+var hostileValues = []byte{0x00, 0x01, 0x02, 0x7F, 0x80, 0x81, 0xF6, 0xFE, 0xFF}
+
+// Changing any single byte of the headers a probe reads must not make it
+// panic; a crafted partition is probed as soon as it is plugged in.
+func TestReadFilesystemHostile(t *testing.T) {
+	regions := map[string][][2]int{
+		"btrfs": {{64 << 10, 64<<10 + 0x12B + 256}},
+		"ntfs":  {{4*4096 + 3*1024, 4*4096 + 4*1024}},
+		"exfat": {{64 * 512, 64*512 + 64}},
+		"fat12": {{(1 + 2*8) * 512, (1+2*8)*512 + 64}},
+	}
+	for _, tt := range fsCases() {
+		for _, r := range append([][2]int{{0, 4096}}, regions[tt.name]...) {
+			for off := r[0]; off < r[1]; off++ {
+				orig := tt.img[off]
+				for _, v := range hostileValues {
+					tt.img[off] = v
+					if _, err := readFilesystem(bytes.NewReader(tt.img), uint64(len(tt.img))); err != nil {
+						t.Fatalf("%s with 0x%02X at %d: %v", tt.name, v, off, err)
+					}
+				}
+				tt.img[off] = orig
+			}
+		}
+	}
+}
+
+func TestProbeNTFSRecordSize(t *testing.T) {
+	for _, tt := range []struct {
+		value byte
+		label string
+	}{
+		{0xF6, "Windows"},
+		// -128 negates to itself as an int8.
+		{0x80, ""},
+		{0xE0, ""},
+		{0x00, ""},
+		{0x7F, ""},
+	} {
+		img := ntfsImage()
+		img[0x40] = tt.value
+		got, err := readFilesystem(bytes.NewReader(img), uint64(len(img)))
+		if err != nil {
+			t.Fatalf("0x%02X: %v", tt.value, err)
+		}
+		if got.Type != "ntfs" || got.Label != tt.label {
+			t.Errorf("0x%02X: got %+v, want ntfs labelled %q", tt.value, got, tt.label)
+		}
+	}
+}
+
+func TestProbeNTFSBadUpdateSequence(t *testing.T) {
+	img := ntfsImage()
+	rec := img[4*4096+3*1024:]
+	// An update sequence array that runs past the record.
+	binary.LittleEndian.PutUint16(rec[4:], 1020)
+	binary.LittleEndian.PutUint16(rec[6:], 0xFFFF)
+	if got, err := readFilesystem(bytes.NewReader(img), uint64(len(img))); err != nil || got.Label != "" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+	// An attribute longer than the record.
+	img = ntfsImage()
+	rec = img[4*4096+3*1024:]
+	binary.LittleEndian.PutUint32(rec[0x38+4:], 0x7FFFFFFF)
+	if got, err := readFilesystem(bytes.NewReader(img), uint64(len(img))); err != nil || got.Label != "" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
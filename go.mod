@@ -5,6 +5,7 @@ go 1.24.6
 require (
 	fyne.io/fyne/v2 v2.6.3
 	fyne.io/systray v1.11.0
+	github.com/BurntSushi/toml v1.4.0
 	github.com/danieljoos/wincred v1.2.2
 	github.com/jaypipes/ghw v0.19.1
 	github.com/zalando/go-keyring v0.2.6
@@ -14,7 +15,6 @@ require (
 
 require (
 	al.essio.dev/pkg/shellescape v1.5.1 // indirect
-	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v1.1.0 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
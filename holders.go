@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errHeld = errors.New("target is held open")
+
+// deviceHolder is a process, or a device stacked on the target such as a
+// device-mapper volume or a loop device, that keeps one of a target's
+// devices busy.
+type deviceHolder struct {
+	Device  string
+	PID     int
+	Command string
+	// Holder names the stacked device when PID is 0. Stacked is set for
+	// device-mapper and md devices, which a stack teardown removes.
+	Holder  string
+	Stacked bool
+	How     string
+}
+
+func (h deviceHolder) String() string {
+	who := h.Holder
+	if h.PID != 0 {
+		who = fmt.Sprintf("PID %d (%s)", h.PID, h.Command)
+	}
+	return who + " " + h.How + " " + h.Device
+}
+
+// unstacked drops the device-mapper and md devices from holders.
+func unstacked(holders []deviceHolder) []deviceHolder {
+	list := []deviceHolder{}
+	for _, h := range holders {
+		if !h.Stacked {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+func holderList(holders []deviceHolder) string {
+	list := []string{}
+	for _, h := range holders {
+		list = append(list, h.String())
+	}
+	return strings.Join(list, "\n")
+}
+
+// checkHolders refuses a target that anything still holds open, leaving out
+// stacked devices when those are about to be torn down. Mounts are not
+// holders; they are handled, and unmounted, separately.
+func checkHolders(t WipeTarget, teardown bool) error {
+	holders, err := targetHolders(t)
+	if err != nil {
+		return fmt.Errorf("finding processes using %s: %w", t.Path, err)
+	}
+	if teardown {
+		holders = unstacked(holders)
+	}
+	if len(holders) > 0 {
+		return fmt.Errorf("%s: %w:\n%s", t.Path, errHeld, holderList(holders))
+	}
+	return nil
+}
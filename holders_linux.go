@@ -0,0 +1,213 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// targetHolders scans /sys/class/block for device-mapper, md and loop
+// devices built on the target or its partitions, and /proc for processes
+// that have one of those devices, or a file on a filesystem on them, open,
+// mapped or as their working directory. Processes that exit or cannot be
+// read during the scan are skipped, as is Wipr itself.
+func targetHolders(t WipeTarget) ([]deviceHolder, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	// devices maps the kernel name of each of the target's devices, and of
+	// loop devices backed by a file on them, to the target device's path.
+	devices := map[string]string{}
+	for _, target := range t.devices() {
+		dir, err := sysfsBlockDir(target.Path)
+		if err != nil {
+			// Image files have no block device to hold.
+			continue
+		}
+		devices[filepath.Base(dir)] = target.Path
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	cache := map[string]string{}
+	// uses returns the target device that the block device numbered dev
+	// sits on, if any.
+	uses := func(dev string) (string, bool) {
+		if path, ok := cache[dev]; ok {
+			return path, path != ""
+		}
+		cache[dev] = ""
+		if name, ok := resolveBlockDev(dev, mounts); ok {
+			for _, n := range blockStack(name) {
+				if path, ok := devices[n]; ok {
+					cache[dev] = path
+					break
+				}
+			}
+		}
+		return cache[dev], cache[dev] != ""
+	}
+
+	holders := []deviceHolder{}
+	entries, err := os.ReadDir("/sys/class/block")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		dir := filepath.Join("/sys/class/block", name)
+		if _, ok := devices[name]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+			// Partitions are reported through the device they belong to.
+			continue
+		}
+		if b, err := os.ReadFile(filepath.Join(dir, "loop", "backing_file")); err == nil {
+			file := strings.TrimSpace(string(b))
+			dev, err := deviceNumber(file)
+			if err != nil {
+				dev, err = fileDeviceNumber(file)
+			}
+			if err != nil {
+				continue
+			}
+			if path, ok := uses(dev); ok {
+				holders = append(holders, deviceHolder{Device: path, Holder: "/dev/" + name, How: "is backed by " + file + " on"})
+				devices[name] = path
+			}
+			continue
+		}
+		for _, n := range blockStack(name)[1:] {
+			if path, ok := devices[n]; ok {
+				holder := "/dev/" + name
+				if b, err := os.ReadFile(filepath.Join(dir, "dm", "name")); err == nil {
+					holder += " (" + strings.TrimSpace(string(b)) + ")"
+				}
+				holders = append(holders, deviceHolder{Device: path, Holder: holder, Stacked: true, How: "is built on"})
+				break
+			}
+		}
+	}
+	// Loop devices found above change what sits on the target.
+	cache = map[string]string{}
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	for _, p := range procs {
+		if pid, err := strconv.Atoi(p.Name()); err == nil && pid != os.Getpid() {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	for _, pid := range pids {
+		dir := filepath.Join("/proc", strconv.Itoa(pid))
+		found := map[deviceHolder]bool{}
+		add := func(path, how string) {
+			found[deviceHolder{Device: path, PID: pid, How: how}] = true
+		}
+		fds, _ := os.ReadDir(filepath.Join(dir, "fd"))
+		for _, fd := range fds {
+			var st unix.Stat_t
+			if err := unix.Stat(filepath.Join(dir, "fd", fd.Name()), &st); err != nil {
+				continue
+			}
+			if st.Mode&unix.S_IFMT == unix.S_IFBLK {
+				if path, ok := uses(fmt.Sprintf("%d:%d", unix.Major(st.Rdev), unix.Minor(st.Rdev))); ok {
+					add(path, "holds")
+				}
+			} else if path, ok := uses(fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev))); ok {
+				add(path, "has files open on")
+			}
+		}
+		for _, dev := range mappedDevices(filepath.Join(dir, "maps")) {
+			if path, ok := uses(dev); ok {
+				add(path, "maps files on")
+			}
+		}
+		if dev, err := fileDeviceNumber(filepath.Join(dir, "cwd")); err == nil {
+			if path, ok := uses(dev); ok {
+				add(path, "has its working directory on")
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		command := processCommand(dir)
+		list := []deviceHolder{}
+		for h := range found {
+			h.Command = command
+			list = append(list, h)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Device+list[i].How < list[j].Device+list[j].How
+		})
+		holders = append(holders, list...)
+	}
+	return holders, nil
+}
+
+// mappedDevices returns the "major:minor" of every device that a process
+// maps a file from.
+func mappedDevices(maps string) []string {
+	f, err := os.Open(maps)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	seen := map[string]bool{}
+	devs := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address perms offset dev inode path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[4] == "0" {
+			continue
+		}
+		majmin := strings.SplitN(fields[3], ":", 2)
+		if len(majmin) != 2 {
+			continue
+		}
+		major, err1 := strconv.ParseUint(majmin[0], 16, 32)
+		minor, err2 := strconv.ParseUint(majmin[1], 16, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		dev := fmt.Sprintf("%d:%d", major, minor)
+		if !seen[dev] {
+			seen[dev] = true
+			devs = append(devs, dev)
+		}
+	}
+	return devs
+}
+
+// processCommand returns the command line of the process whose /proc
+// directory is dir, shortened to fit a dialog, or its name in brackets for
+// kernel threads.
+func processCommand(dir string) string {
+	const maxCommand = 120
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(b) > 0 {
+		command := []rune(strings.TrimSpace(strings.ReplaceAll(strings.TrimRight(string(b), "\x00"), "\x00", " ")))
+		if len(command) > maxCommand {
+			return string(command[:maxCommand]) + "…"
+		}
+		return string(command)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		return "[" + strings.TrimSpace(string(b)) + "]"
+	}
+	return "?"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var errDeviceRemoved = errors.New("device was removed")
+
+// hotplugSettle is how long the device list waits after the last event
+// before it is read again, so udev has created its links by then and a
+// burst of events causes a single refresh.
+const hotplugSettle = time.Second
+
+// deviceEvent is a block device being added, removed or changed. Path is
+// empty where the platform cannot tell which device it was.
+type deviceEvent struct {
+	Action string
+	Path   string
+}
+
+var hotplug struct {
+	sync.Mutex
+	started     bool
+	next        int
+	subscribers map[int]func(deviceEvent)
+}
+
+// subscribeDevices calls fn, from the watcher's goroutine, for every device
+// event until the returned function is called. The watcher starts with
+// the first subscriber.
+func subscribeDevices(fn func(deviceEvent)) func() {
+	hotplug.Lock()
+	defer hotplug.Unlock()
+	if hotplug.subscribers == nil {
+		hotplug.subscribers = map[int]func(deviceEvent){}
+	}
+	id := hotplug.next
+	hotplug.next++
+	hotplug.subscribers[id] = fn
+	if !hotplug.started {
+		hotplug.started = true
+		go func() {
+			if err := watchDevices(publishDevice); err != nil {
+				log.Println("watching devices:", err)
+			}
+		}()
+	}
+	return func() {
+		hotplug.Lock()
+		defer hotplug.Unlock()
+		delete(hotplug.subscribers, id)
+	}
+}
+
+func publishDevice(ev deviceEvent) {
+	hotplug.Lock()
+	subscribers := make([]func(deviceEvent), 0, len(hotplug.subscribers))
+	for _, fn := range hotplug.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	hotplug.Unlock()
+	for _, fn := range subscribers {
+		fn(ev)
+	}
+}
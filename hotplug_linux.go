@@ -0,0 +1,58 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchDevices listens for the kernel's uevents on a netlink socket and
+// passes on those of block devices. It only returns on error.
+func watchDevices(publish func(deviceEvent)) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	// Group 1 carries the kernel's own events, group 2 udev's.
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		return err
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.EINTR || err == unix.ENOBUFS {
+			// ENOBUFS means events were dropped; the next one still
+			// triggers a full refresh.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ev, ok := parseUevent(buf[:n]); ok {
+			publish(ev)
+		}
+	}
+}
+
+// parseUevent reads a kernel uevent: "action@devpath" followed by
+// NUL-separated KEY=value pairs.
+func parseUevent(msg []byte) (deviceEvent, bool) {
+	env := map[string]string{}
+	for _, field := range bytes.Split(msg, []byte{0}) {
+		if key, value, ok := strings.Cut(string(field), "="); ok {
+			env[key] = value
+		}
+	}
+	if env["SUBSYSTEM"] != "block" || env["ACTION"] == "" {
+		return deviceEvent{}, false
+	}
+	ev := deviceEvent{Action: env["ACTION"]}
+	if env["DEVNAME"] != "" {
+		ev.Path = "/dev/" + strings.TrimPrefix(env["DEVNAME"], "/dev/")
+	}
+	return ev, true
+}
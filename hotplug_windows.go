@@ -0,0 +1,40 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const hotplugPoll = 2 * time.Second
+
+// watchDevices polls the drive letters in use, as a window-less program
+// gets no device notifications, and reports volumes that appear or go
+// away. It only returns on error.
+func watchDevices(publish func(deviceEvent)) error {
+	last, err := windows.GetLogicalDrives()
+	if err != nil {
+		return err
+	}
+	for {
+		time.Sleep(hotplugPoll)
+		drives, err := windows.GetLogicalDrives()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 26; i++ {
+			bit := uint32(1) << i
+			path := fmt.Sprintf(`\\.\%c:`, 'A'+i)
+			switch {
+			case drives&bit != 0 && last&bit == 0:
+				publish(deviceEvent{Action: "add", Path: path})
+			case drives&bit == 0 && last&bit != 0:
+				publish(deviceEvent{Action: "remove", Path: path})
+			}
+		}
+		last = drives
+	}
+}
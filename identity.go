@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaypipes/ghw"
+)
+
+var errDeviceChanged = errors.New("device changed since it was selected")
+
+// DeviceID identifies a disk or partition independently of the /dev name
+// or drive letter it happens to have, which can move between boots or when
+// drives are swapped.
+type DeviceID struct {
+	Serial string
+	WWN    string
+	// ByID is the /dev/disk/by-id link for the device, on Linux.
+	ByID string
+	// PartUUID identifies a partition on its disk.
+	PartUUID string
+}
+
+func (id DeviceID) String() string {
+	parts := []string{}
+	if id.Serial != "" {
+		parts = append(parts, "S/N "+id.Serial)
+	}
+	if id.WWN != "" {
+		parts = append(parts, "WWN "+id.WWN)
+	}
+	if id.PartUUID != "" {
+		parts = append(parts, "PARTUUID "+id.PartUUID)
+	}
+	if id.ByID != "" {
+		parts = append(parts, id.ByID)
+	}
+	if len(parts) == 0 {
+		return "no serial or WWN"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Key is the most specific identifier known for the device.
+func (id DeviceID) Key() string {
+	for _, key := range []string{id.ByID, id.WWN, id.Serial} {
+		if key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// ghwValue drops the placeholder ghw reports for values it could not read.
+func ghwValue(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "unknown") {
+		return ""
+	}
+	return s
+}
+
+func diskID(d *ghw.Disk, path string) DeviceID {
+	return DeviceID{Serial: ghwValue(d.SerialNumber), WWN: ghwValue(d.WWN), ByID: byIDPath(path)}
+}
+
+func partitionID(p *ghw.Partition, path string) DeviceID {
+	id := DeviceID{PartUUID: ghwValue(p.UUID), ByID: byIDPath(path)}
+	if p.Disk != nil {
+		id.Serial = ghwValue(p.Disk.SerialNumber)
+		id.WWN = ghwValue(p.Disk.WWN)
+	}
+	return id
+}
+
+// verifyIdentity enumerates the devices again and refuses the target, or
+// any of its partitions, if the device now at its path is not the one that
+// was selected. Model and size are compared too, since not every device
+// reports a serial number or WWN.
+func verifyIdentity(t WipeTarget) error {
+	// Only enumerated devices know their disk; image files are taken as is.
+	if t.Disk == "" {
+		return nil
+	}
+	block, err := ghw.Block()
+	if err != nil {
+		return fmt.Errorf("re-reading devices: %w", err)
+	}
+	current := map[string]WipeTarget{}
+	for _, d := range block.Disks {
+		dt := diskTarget(d)
+		current[dt.Path] = dt
+		for _, p := range d.Partitions {
+			pt := partitionTarget(p)
+			current[pt.Path] = pt
+		}
+	}
+	for _, target := range t.devices() {
+		found, ok := current[target.Path]
+		if !ok {
+			return fmt.Errorf("%s: %w: device is gone", target.Path, errDeviceChanged)
+		}
+		if target.Offset > 0 {
+			// A partition only the partition table knows about is checked
+			// against its disk and the table it was found in.
+			if found.ID.Serial != target.ID.Serial || found.ID.WWN != target.ID.WWN || found.ID.ByID != target.ID.ByID {
+				return fmt.Errorf("%s: %w: selected a partition of %s, found %s", target.Path, errDeviceChanged, target.ID, found.ID)
+			}
+			if err := verifyTablePartition(found, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if found.ID != target.ID {
+			return fmt.Errorf("%s: %w: selected %s, found %s", target.Path, errDeviceChanged, target.ID, found.ID)
+		}
+		if found.Name != target.Name || found.Size != target.Size {
+			return fmt.Errorf("%s: %w: selected %s (%d bytes), found %s (%d bytes)", target.Path, errDeviceChanged,
+				target.Name, target.Size, found.Name, found.Size)
+		}
+	}
+	return nil
+}
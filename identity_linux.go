@@ -0,0 +1,41 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const byIDDir = "/dev/disk/by-id"
+
+// byIDPath finds the /dev/disk/by-id link that points at devPath. Links
+// named after the bus, model and serial are preferred over wwn- and eui.
+// links, which are already covered by the WWN.
+func byIDPath(devPath string) string {
+	entries, err := os.ReadDir(byIDDir)
+	if err != nil {
+		return ""
+	}
+	links := []string{}
+	for _, e := range entries {
+		link := filepath.Join(byIDDir, e.Name())
+		if target, err := filepath.EvalSymlinks(link); err == nil && target == devPath {
+			links = append(links, link)
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return !isWWNLink(links[i]) && isWWNLink(links[j])
+	})
+	if len(links) == 0 {
+		return ""
+	}
+	return links[0]
+}
+
+func isWWNLink(link string) bool {
+	name := filepath.Base(link)
+	return strings.HasPrefix(name, "wwn-") || strings.HasPrefix(name, "nvme-eui.")
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// byIDPath is empty on Windows, which has no by-id links; disks are told
+// apart by serial number and WWN alone.
+func byIDPath(devPath string) string {
+	return ""
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// keystream is AES-256 in counter mode over an all-zero plaintext. Block n
+// of the stream covers bytes 16n to 16n+15, so the bytes at any offset can
+// be produced on demand both when writing a random pass and when verifying
+// it.
+type keystream struct {
+	block cipher.Block
+}
+
+func newKeystream(seed string) *keystream {
+	key := sha256.Sum256([]byte(seed))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		// A 32-byte key is always valid for AES-256.
+		panic(err)
+	}
+	return &keystream{block: block}
+}
+
+// ReadAt fills p with the stream bytes starting at offset off.
+func (k *keystream) ReadAt(p []byte, off uint64) {
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[8:], off/aes.BlockSize)
+	ctr := cipher.NewCTR(k.block, iv[:])
+	if skip := off % aes.BlockSize; skip != 0 {
+		var discard [aes.BlockSize]byte
+		ctr.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	clear(p)
+	ctr.XORKeyStream(p, p)
+}
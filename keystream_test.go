@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestKeystreamUnalignedReadAt(t *testing.T) {
+	const seed = "keystream test seed"
+	key := sha256.Sum256([]byte(seed))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The whole stream from offset 0 in one go, as AES-CTR produces it.
+	want := make([]byte, 4096)
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(want, want)
+
+	k := newKeystream(seed)
+	for _, off := range []uint64{0, 1, 15, 16, 17, 1000} {
+		for _, n := range []int{1, 15, 16, 33, 1000} {
+			got := make([]byte, n)
+			k.ReadAt(got, off)
+			if !bytes.Equal(got, want[off:off+uint64(n)]) {
+				t.Errorf("ReadAt(%d bytes, %d) does not match the contiguous stream", n, off)
+			}
+		}
+	}
+}
+
+func BenchmarkKeystreamReadAt(b *testing.B) {
+	k := newKeystream("benchmark")
+	buf := make([]byte, wipeChunkSize)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		k.ReadAt(buf, uint64(i)*uint64(len(buf)))
+	}
+}
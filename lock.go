@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+var errLocked = errors.New("device is being wiped by another Wipr")
+
+// lockName names the lock file for the disk a target is on, so that two
+// Wipr instances can never wipe the same disk, or a disk and one of its
+// partitions, at once.
+func lockName(t WipeTarget) string {
+	path := t.Disk
+	if path == "" {
+		path = t.Path
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimLeft(path, `/\.`))
+}
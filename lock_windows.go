@@ -0,0 +1,42 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockDevice takes the lock for the target's disk and records our PID in
+// it. The lock is released when the returned file is closed, or when the
+// process dies.
+func lockDevice(t WipeTarget) (*os.File, error) {
+	dir := filepath.Join(os.Getenv("ProgramData"), "Wipr")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, lockName(t)+".lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Lock a byte past the end of the file so the PID stays readable.
+	overlapped := windows.Overlapped{OffsetHigh: 1}
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &overlapped); err != nil {
+		pid, _ := os.ReadFile(path)
+		f.Close()
+		if err == windows.ERROR_LOCK_VIOLATION {
+			return nil, fmt.Errorf("%s: %w (PID %s)", t.Path, errLocked, strings.TrimSpace(string(pid)))
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return f, nil
+}
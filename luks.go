@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+var (
+	luksMagic           = []byte{'L', 'U', 'K', 'S', 0xBA, 0xBE}
+	luks2SecondaryMagic = []byte{'S', 'K', 'U', 'L', 0xBA, 0xBE}
+	// LUKS2 places its secondary header at one of these offsets, equal to the
+	// size of the primary header and its JSON area.
+	luks2SecondaryOffsets = []int64{16 << 10, 32 << 10, 64 << 10, 128 << 10, 256 << 10, 512 << 10, 1 << 20, 2 << 20, 4 << 20}
+	errNotLUKS            = errors.New("no LUKS header found")
+)
+
+const (
+	luks1HeaderSize   = 592
+	luks1KeySlots     = 8
+	luks2BinarySize   = 4096
+	luksSectorSize    = 512
+	luks1KeySlotStart = 208
+	luks1KeySlotSize  = 48
+)
+
+// LUKSHeader describes the part of a LUKS device that holds key material:
+// destroying the first End bytes makes the encrypted data unrecoverable.
+type LUKSHeader struct {
+	Version int
+	End     uint64
+}
+
+func (h LUKSHeader) String() string {
+	return fmt.Sprintf("LUKS%d", h.Version)
+}
+
+// detectLUKS looks for a LUKS1 or LUKS2 header, falling back to the LUKS2
+// secondary header when the primary one is damaged.
+func detectLUKS(r io.ReaderAt) (LUKSHeader, error) {
+	hdr := make([]byte, luks2BinarySize)
+	if _, err := r.ReadAt(hdr, 0); err != nil && !errors.Is(err, io.EOF) {
+		return LUKSHeader{}, err
+	}
+	if bytes.HasPrefix(hdr, luksMagic) {
+		switch binary.BigEndian.Uint16(hdr[6:8]) {
+		case 1:
+			return parseLUKS1(hdr)
+		case 2:
+			return parseLUKS2(r, hdr)
+		}
+	}
+	for _, off := range luks2SecondaryOffsets {
+		if _, err := r.ReadAt(hdr, off); err != nil {
+			break
+		}
+		if bytes.HasPrefix(hdr, luks2SecondaryMagic) && binary.BigEndian.Uint16(hdr[6:8]) == 2 {
+			return parseLUKS2(r, hdr)
+		}
+	}
+	return LUKSHeader{}, errNotLUKS
+}
+
+func parseLUKS1(hdr []byte) (LUKSHeader, error) {
+	h := LUKSHeader{Version: 1, End: luks1HeaderSize}
+	payload := uint64(binary.BigEndian.Uint32(hdr[104:108])) * luksSectorSize
+	keyBytes := uint64(binary.BigEndian.Uint32(hdr[108:112]))
+	for i := 0; i < luks1KeySlots; i++ {
+		slot := hdr[luks1KeySlotStart+i*luks1KeySlotSize:]
+		offset := uint64(binary.BigEndian.Uint32(slot[40:44])) * luksSectorSize
+		stripes := uint64(binary.BigEndian.Uint32(slot[44:48]))
+		length := (keyBytes*stripes + luksSectorSize - 1) / luksSectorSize * luksSectorSize
+		h.End = max(h.End, offset+length)
+	}
+	// Everything before the payload is header, key material or padding.
+	h.End = max(h.End, payload)
+	return h, nil
+}
+
+type luks2Metadata struct {
+	Keyslots map[string]struct {
+		Area struct {
+			Offset string `json:"offset"`
+			Size   string `json:"size"`
+		} `json:"area"`
+	} `json:"keyslots"`
+	Segments map[string]struct {
+		Offset string `json:"offset"`
+	} `json:"segments"`
+	Config struct {
+		KeyslotsSize string `json:"keyslots_size"`
+	} `json:"config"`
+}
+
+func parseLUKS2(r io.ReaderAt, hdr []byte) (LUKSHeader, error) {
+	hdrSize := binary.BigEndian.Uint64(hdr[8:16])
+	hdrOffset := binary.BigEndian.Uint64(hdr[256:264])
+	if hdrSize < luks2BinarySize || hdrSize > 4<<20 {
+		return LUKSHeader{}, fmt.Errorf("LUKS2 header size %d is invalid", hdrSize)
+	}
+	h := LUKSHeader{Version: 2, End: 2 * hdrSize}
+
+	area := make([]byte, hdrSize-luks2BinarySize)
+	if _, err := r.ReadAt(area, int64(hdrOffset+luks2BinarySize)); err != nil && !errors.Is(err, io.EOF) {
+		return h, nil
+	}
+	var meta luks2Metadata
+	if err := json.Unmarshal(bytes.TrimRight(area, "\x00"), &meta); err != nil {
+		// Without the JSON area only the two headers are known for sure.
+		return h, nil
+	}
+	if size, err := strconv.ParseUint(meta.Config.KeyslotsSize, 10, 64); err == nil {
+		h.End = max(h.End, 2*hdrSize+size)
+	}
+	for _, ks := range meta.Keyslots {
+		offset, err1 := strconv.ParseUint(ks.Area.Offset, 10, 64)
+		size, err2 := strconv.ParseUint(ks.Area.Size, 10, 64)
+		if err1 == nil && err2 == nil {
+			h.End = max(h.End, offset+size)
+		}
+	}
+	for _, seg := range meta.Segments {
+		if offset, err := strconv.ParseUint(seg.Offset, 10, 64); err == nil && offset > h.End {
+			h.End = offset
+		}
+	}
+	return h, nil
+}
+
+// probeLUKS reports the LUKS header of a target, if it has one. A partition
+// of a disk image is probed at its offset in the image.
+func probeLUKS(t WipeTarget) (LUKSHeader, bool) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return LUKSHeader{}, false
+	}
+	defer f.Close()
+	var r io.ReaderAt = f
+	if t.Offset > 0 {
+		r = io.NewSectionReader(f, int64(t.Offset), int64(t.Size))
+	}
+	h, err := detectLUKS(r)
+	return h, err == nil
+}
+
+func luksSupported(t WipeTarget) error {
+	if _, ok := probeLUKS(t); !ok {
+		return errors.New("target has no LUKS header")
+	}
+	return nil
+}
+
+// cryptoErasePass overwrites the LUKS headers and all keyslot material with
+// random data, then re-reads the device to make sure no header survived.
+func cryptoErasePass(f *deviceFile, size uint64, ctl wipeControl, progress func(uint64)) (uint64, error) {
+	h, err := detectLUKS(f)
+	if err != nil {
+		return 0, err
+	}
+	end := min(h.End, size)
+	buf := make([]byte, wipeChunkSize)
+	var written uint64
+	for written < end {
+		if err := ctl.checkpoint(); err != nil {
+			return written, err
+		}
+		chunk := buf[:min(uint64(len(buf)), end-written)]
+		if _, err := rand.Read(chunk); err != nil {
+			return written, err
+		}
+		n, err := f.WriteAt(chunk, int64(written))
+		written += uint64(n)
+		if err != nil {
+			return written, fmt.Errorf("write at offset %d: %w", written, err)
+		}
+		progress(written)
+	}
+	if err := f.Sync(); err != nil {
+		return written, err
+	}
+	if err := dropCache(f.File); err != nil {
+		return written, err
+	}
+	if h, err := detectLUKS(f); err == nil {
+		return written, fmt.Errorf("%s header still present after crypto-erase", h)
+	} else if !errors.Is(err, errNotLUKS) {
+		return written, err
+	}
+	return written, nil
+}
+
+// luksSpan is how many bytes a crypto-erase pass will write on f.
+func luksSpan(f *deviceFile, size uint64) (uint64, error) {
+	h, err := detectLUKS(f)
+	if err != nil {
+		return 0, err
+	}
+	return min(h.End, size), nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// luks1Header has 32-byte keys split into 4000 stripes, slot i starting at
+// sector 8+256*i, and the payload at payloadSector.
+func luks1Header(payloadSector uint32) []byte {
+	hdr := make([]byte, luks1HeaderSize)
+	copy(hdr, luksMagic)
+	binary.BigEndian.PutUint16(hdr[6:], 1)
+	binary.BigEndian.PutUint32(hdr[104:], payloadSector)
+	binary.BigEndian.PutUint32(hdr[108:], 32)
+	for i := 0; i < luks1KeySlots; i++ {
+		slot := hdr[luks1KeySlotStart+i*luks1KeySlotSize:]
+		binary.BigEndian.PutUint32(slot[40:], uint32(8+256*i))
+		binary.BigEndian.PutUint32(slot[44:], 4000)
+	}
+	return hdr
+}
+
+// luks2JSON puts the keyslots below 4 MiB and the data segment at 16 MiB,
+// past the end of the test images.
+const luks2JSON = `{"keyslots":{"0":{"area":{"offset":"32768","size":"258048"}},"1":{"area":{"offset":"290816","size":"258048"}}},` +
+	`"segments":{"0":{"offset":"16777216"}},"config":{"keyslots_size":"4161536"}}`
+
+// luks2Header writes a 16 KiB LUKS2 header and its JSON area at off.
+func luks2Header(img []byte, off int, magic []byte, json string) {
+	hdr := img[off:]
+	copy(hdr, magic)
+	binary.BigEndian.PutUint16(hdr[6:], 2)
+	binary.BigEndian.PutUint64(hdr[8:], 16<<10)
+	binary.BigEndian.PutUint64(hdr[256:], uint64(off))
+	copy(hdr[luks2BinarySize:], json)
+}
+
+func TestDetectLUKS(t *testing.T) {
+	newImage := func() []byte { return make([]byte, 64<<10) }
+	tests := []struct {
+		name string
+		img  func() []byte
+		want LUKSHeader
+	}{
+		{"luks1", func() []byte {
+			img := newImage()
+			copy(img, luks1Header(4096))
+			return img
+		}, LUKSHeader{Version: 1, End: 4096 * luksSectorSize}},
+		{"luks1 keyslots past the payload", func() []byte {
+			img := newImage()
+			copy(img, luks1Header(2))
+			return img
+		}, LUKSHeader{Version: 1, End: (8+256*7)*luksSectorSize + 128000}},
+		{"luks2", func() []byte {
+			img := newImage()
+			luks2Header(img, 0, luksMagic, luks2JSON)
+			luks2Header(img, 16<<10, luks2SecondaryMagic, luks2JSON)
+			return img
+		}, LUKSHeader{Version: 2, End: 16 << 20}},
+		{"luks2 secondary header", func() []byte {
+			img := newImage()
+			luks2Header(img, 16<<10, luks2SecondaryMagic, luks2JSON)
+			return img
+		}, LUKSHeader{Version: 2, End: 16 << 20}},
+		{"luks2 damaged JSON", func() []byte {
+			img := newImage()
+			luks2Header(img, 0, luksMagic, `{"keyslots":`)
+			return img
+		}, LUKSHeader{Version: 2, End: 32 << 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectLUKS(bytes.NewReader(tt.img()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for name, img := range map[string][]byte{
+		"blank":     newImage(),
+		"truncated": luksMagic,
+		"version 3": append(append([]byte{}, luksMagic...), 0, 3),
+	} {
+		if _, err := detectLUKS(bytes.NewReader(img)); !errors.Is(err, errNotLUKS) {
+			t.Errorf("%s: got %v, want %v", name, err, errNotLUKS)
+		}
+	}
+	img := newImage()
+	luks2Header(img, 0, luksMagic, luks2JSON)
+	binary.BigEndian.PutUint64(img[8:], 1<<40)
+	if _, err := detectLUKS(bytes.NewReader(img)); err == nil {
+		t.Error("LUKS2 header of 1 TiB accepted")
+	}
+}
+
+// A LUKS partition in a disk image: the crypto-erase must destroy its
+// header and keyslots, and nothing before or after them.
+func TestCryptoErasePass(t *testing.T) {
+	const (
+		partOff  = 1 << 20
+		partSize = 4 << 20
+		end      = 2 << 20
+	)
+	img := make([]byte, partOff+partSize+4096)
+	if _, err := rand.Read(img); err != nil {
+		t.Fatal(err)
+	}
+	copy(img[partOff:], luks1Header(end/luksSectorSize))
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := WipeTarget{Name: "disk.img1", Path: path, Offset: partOff, Size: partSize}
+	if err := luksSupported(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := luksSupported(WipeTarget{Name: "disk.img", Path: path, Size: uint64(len(img))}); err == nil {
+		t.Error("whole image reported as LUKS")
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	df := &deviceFile{File: f, off: partOff, size: partSize}
+	if span, err := luksSpan(df, partSize); err != nil || span != end {
+		t.Fatalf("luksSpan = %d, %v; want %d", span, err, end)
+	}
+	var last uint64
+	written, err := cryptoErasePass(df, partSize, newJobControl().ctl(), func(n uint64) { last = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != end || last != end {
+		t.Errorf("wrote %d bytes, progress %d, want %d", written, last, end)
+	}
+	if err := luksSupported(target); err == nil {
+		t.Error("LUKS header found after the crypto-erase")
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:partOff], img[:partOff]) {
+		t.Error("data before the partition changed")
+	}
+	if !bytes.Equal(got[partOff+end:], img[partOff+end:]) {
+		t.Error("encrypted data past the keyslots changed")
+	}
+	if bytes.Equal(got[partOff+luks1HeaderSize:partOff+end], img[partOff+luks1HeaderSize:partOff+end]) {
+		t.Error("keyslot area unchanged")
+	}
+}
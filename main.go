@@ -11,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -30,6 +32,7 @@ type Config struct {
 	MinimizeOnClose bool
 	EnterpriseMode  bool
 	PassKey         string
+	VerifyPercent   int
 }
 
 func ternary[T any](cond bool, iftrue T, iffalse T) T {
@@ -46,7 +49,7 @@ const (
 
 var (
 	isWiping       = false
-	config         Config
+	config         = Config{VerifyPercent: 100}
 	driveMap       = make(map[string]*ghw.Disk)
 	partitionMap   = make(map[string]*ghw.Partition)
 	showWinSystray *systray.MenuItem
@@ -69,26 +72,56 @@ func DeleteKey() {
 	keyring.Delete("Wipr_verify", "Wipr_user")
 }
 
-func List_Drives() []string {
+// List_Drives keys disks by serial number, WWN or by-id link, falling back
+// to the model and name for disks that report none of them. It only reads
+// the system, so it can run off the UI goroutine; the caller fills
+// driveMap in.
+func List_Drives() ([]string, map[string]*ghw.Disk) {
 	block, _ := ghw.Block()
 	drives := []string{}
+	found := map[string]*ghw.Disk{}
 	for _, d := range block.Disks {
-		driveMap[d.Model] = d
-		drives = append(drives, d.Model)
+		key := diskTarget(d).ID.Key()
+		if key == "" || slices.Contains(drives, key) {
+			key = fmt.Sprintf("%s %s", d.Model, d.Name)
+		}
+		found[key] = d
+		drives = append(drives, key)
 	}
-	return drives
+	return drives, found
 }
 
-func List_Partitions() []string {
+func List_Partitions() ([]string, map[string]*ghw.Partition) {
 	block, _ := ghw.Block()
 	paritions := []string{}
+	found := map[string]*ghw.Partition{}
 	for _, d := range block.Disks {
 		for _, p := range d.Partitions {
-			paritions = append(paritions, fmt.Sprintf("%s %s", p.Name, d.Model))
-			partitionMap[fmt.Sprintf("%s %s", p.Name, d.Model)] = p
+			id := partitionTarget(p).ID
+			key := ternary(id.ByID != "", id.ByID, id.PartUUID)
+			if key == "" || slices.Contains(paritions, key) {
+				key = fmt.Sprintf("%s %s", p.Name, d.Model)
+			}
+			paritions = append(paritions, key)
+			found[key] = p
+		}
+	}
+	return paritions, found
+}
+
+// targetByPath finds the listed drive or partition at path.
+func targetByPath(path string) (WipeTarget, bool) {
+	for _, d := range driveMap {
+		if t := diskTarget(d); t.Path == path {
+			return t, true
 		}
 	}
-	return paritions
+	for _, p := range partitionMap {
+		if t := partitionTarget(p); t.Path == path {
+			return t, true
+		}
+	}
+	return WipeTarget{}, false
 }
 
 func formatBytes(b uint64) string {
@@ -158,6 +191,9 @@ func main() {
 	if !isElevated {
 		os.Exit(0)
 	}
+	if ok, status := runCommand(os.Args[1:]); ok {
+		os.Exit(status)
+	}
 	wipr := app.New()
 	window := wipr.NewWindow("Wipr")
 	window.Resize(fyne.NewSize(WIDTH, HEIGHT))
@@ -248,10 +284,16 @@ func main() {
 				config.MinimizeOnClose = b
 			})
 			mOC.Checked = config.MinimizeOnClose
+			verifyOptions := widget.NewSelect([]string{"Full", "50% sample", "10% sample", "1% sample"}, func(s string) {
+				percent, _ := strconv.Atoi(strings.TrimSuffix(s, "% sample"))
+				config.VerifyPercent = ternary(s == "Full", 100, percent)
+			})
+			verifyOptions.SetSelected(ternary(config.VerifyPercent == 100, "Full", fmt.Sprintf("%d%% sample", config.VerifyPercent)))
 			box := container.New(NewCustomPaddedBoxLayout(5, 5),
 				container.NewPadded(
 					container.NewVBox(
 						mOC,
+						container.NewGridWithColumns(2, widget.NewLabel("Verification"), verifyOptions),
 						checkB,
 						widget.NewLabel("Connection Key"),
 						key,
@@ -266,7 +308,7 @@ func main() {
 				),
 			)
 			modal = widget.NewModalPopUp(box, window.Canvas())
-			modal.Resize(fyne.NewSize(WIDTH-300, HEIGHT-100))
+			modal.Resize(fyne.NewSize(WIDTH-300, HEIGHT-50))
 			modal.Show()
 		}),
 		widget.NewToolbarAction(theme.HelpIcon(), func() {
@@ -324,23 +366,112 @@ func main() {
 			layout.NewSpacer(),
 			widget.NewLabel("v"+wipr.Metadata().Version),
 		))
-	drives := List_Drives()
-	partitions := List_Partitions()
 	var wipeBtn *widget.Button
-	selectOptions := widget.NewSelect(drives, func(s string) {
+	var freeSpaceBtn *widget.Button
+	targetTree := newTargetTree()
+	targetTree.OnChanged = func() {
 		if wipeBtn != nil {
-			wipeBtn.Enable()
+			if len(targetTree.Targets()) > 0 {
+				wipeBtn.Enable()
+			} else {
+				wipeBtn.Disable()
+			}
 		}
+		if freeSpaceBtn != nil {
+			if p := targetTree.Partition(); p != nil && p.MountPoint != "" {
+				freeSpaceBtn.Show()
+			} else {
+				freeSpaceBtn.Hide()
+			}
+		}
+	}
+	targetTree.Watch()
+	details := newDetailsPanel()
+	partBar := newPartitionBar()
+	partBar.OnTapped = targetTree.SelectPartition
+	targetTree.OnSelected = func(disk, id string) {
+		partBar.Show(targetTree.Layout(disk), id)
+		details.Show(disk)
+	}
+	targetTree.OnError = func(err error) { dialog.ShowError(err, window) }
+	addImageBtn := widget.NewButtonWithIcon("Add Image", theme.FileIcon(), func() {
+		dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil || r == nil {
+				return
+			}
+			r.Close()
+			targetTree.AddImage(r.URI().Path())
+		}, window)
 	})
-	typeOptions := widget.NewSelect([]string{"By Disk Drive", "By Partitions"}, func(s string) {
-		selectOptions.ClearSelected()
+	targetBox := container.NewVBox(targetTree.Container(), addImageBtn, partBar.Container(), details.Container())
+	var selectedFiles []string
+	filesLabel := widget.NewLabel("No files selected")
+	filesLabel.Truncation = fyne.TextTruncateEllipsis
+	updateFiles := func() {
+		switch len(selectedFiles) {
+		case 0:
+			filesLabel.SetText("No files selected")
+		case 1:
+			filesLabel.SetText(selectedFiles[0])
+		default:
+			filesLabel.SetText(fmt.Sprintf("%d items selected: %s", len(selectedFiles), strings.Join(selectedFiles, ", ")))
+		}
 		if wipeBtn != nil {
-			wipeBtn.Disable()
+			if len(selectedFiles) > 0 {
+				wipeBtn.Enable()
+			} else {
+				wipeBtn.Disable()
+			}
 		}
-		selectOptions.SetOptions(ternary(s == "By Disk Drive", drives, partitions))
+	}
+	filesBox := container.NewVBox(
+		filesLabel,
+		container.NewGridWithColumns(3,
+			widget.NewButtonWithIcon("Add File", theme.FileIcon(), func() {
+				dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+					if err != nil || r == nil {
+						return
+					}
+					r.Close()
+					selectedFiles = append(selectedFiles, r.URI().Path())
+					updateFiles()
+				}, window)
+			}),
+			widget.NewButtonWithIcon("Add Folder", theme.FolderIcon(), func() {
+				dialog.ShowFolderOpen(func(l fyne.ListableURI, err error) {
+					if err != nil || l == nil {
+						return
+					}
+					selectedFiles = append(selectedFiles, l.Path())
+					updateFiles()
+				}, window)
+			}),
+			widget.NewButtonWithIcon("Clear", theme.ContentClearIcon(), func() {
+				selectedFiles = nil
+				updateFiles()
+			}),
+		),
+	)
+	filesBox.Hide()
+	typeOptions := widget.NewSelect([]string{"By Drives & Partitions", "By Files"}, func(s string) {
+		if s == "By Files" {
+			if freeSpaceBtn != nil {
+				freeSpaceBtn.Hide()
+			}
+			targetBox.Hide()
+			filesBox.Show()
+			updateFiles()
+			return
+		}
+		filesBox.Hide()
+		targetBox.Show()
+		targetTree.changed()
 	})
 	typeOptions.SetSelectedIndex(0)
-	selectOptions.SetSelectedIndex(0)
+	recipeErr := loadRecipes()
+	methodOptions := widget.NewSelect(methodNames(), func(s string) {})
+	methodOptions.SetSelected(defaultMethod.Name)
+	details.OnUse = func(m *WipeMethod) { methodOptions.SetSelected(m.Name) }
 	wiprText := canvas.NewText("Wipr", theme.Color(theme.ColorNameForeground))
 	wiprText.TextSize = 20
 	wiprText.Alignment = fyne.TextAlignCenter
@@ -353,40 +484,66 @@ func main() {
 	bg.SetMinSize(fyne.NewSize(WIDTH-100, HEIGHT))
 	var box *fyne.Container
 	wipeBtn = widget.NewButtonWithIcon("Wipe", theme.DeleteIcon(), func() {
-		isWiping = true
+		method := MethodByName(methodOptions.Selected)
+		if method == nil {
+			err := errors.New("invalid method")
+			dialog.ShowError(err, window)
+			return
+		}
+		var targets []WipeTarget
 		switch typeOptions.Selected {
-		case "By Partitions":
-			partition := partitionMap[selectOptions.Selected]
-			if partition == nil {
-				err := errors.New("invalid partition")
+		case "By Drives & Partitions":
+			targets = targetTree.Targets()
+			if len(targets) == 0 {
+				err := errors.New("no drives or partitions selected")
 				dialog.ShowError(err, window)
-				fmt.Println(err)
 				return
 			}
-			wipePartitions(wipr, &window, []*ghw.Partition{partition})
-		case "By Disk Drive":
-			drive := driveMap[selectOptions.Selected]
-			if drive == nil {
-				err := errors.New("invalid drive")
+		case "By Files":
+			if _, err := shredFiles(wipr, &window, selectedFiles, method, func() {
+				selectedFiles = nil
+				updateFiles()
+			}); err != nil {
 				dialog.ShowError(err, window)
-				fmt.Println(err)
-				return
 			}
-			wipePartitions(wipr, &window, drive.Partitions)
+			return
 		default:
 			err := errors.New("invalid mode")
 			dialog.ShowError(err, window)
-			fmt.Println(err)
 			return
 		}
-
+		if _, err := wipeTargets(wipr, &window, targets, method); err != nil {
+			dialog.ShowError(err, window)
+		}
 	})
+	freeSpaceBtn = widget.NewButtonWithIcon("Wipe Free Space", theme.StorageIcon(), func() {
+		method := MethodByName(methodOptions.Selected)
+		partition := targetTree.Partition()
+		if method == nil || partition == nil || partition.MountPoint == "" {
+			err := errors.New("select a mounted partition and a wipe method")
+			dialog.ShowError(err, window)
+			return
+		}
+		mount := partition.MountPoint
+		if !strings.HasSuffix(mount, string(os.PathSeparator)) {
+			mount += string(os.PathSeparator)
+		}
+		if _, err := wipeFreeSpace(wipr, &window, mount, method); err != nil {
+			dialog.ShowError(err, window)
+		}
+	})
+	freeSpaceBtn.Hide()
+	targetTree.changed()
 	box = container.NewVBox(wiprText,
 		spacer,
 		typeOptions,
-		selectOptions,
+		targetBox,
+		filesBox,
+		widget.NewLabel("Wipe Method"),
+		methodOptions,
 		layout.NewSpacer(),
 		verifyBtn,
+		freeSpaceBtn,
 		wipeBtn,
 	)
 	wipeBtn.Importance = widget.DangerImportance
@@ -399,6 +556,9 @@ func main() {
 	content := container.NewBorder(toolbar, btmToolbar, nil, nil, boxWithBg)
 
 	wipr.Lifecycle().SetOnStarted(func() {
+		if recipeErr != nil {
+			dialog.ShowError(recipeErr, window)
+		}
 		systray.Register(func() {
 			systray.SetIcon(resourceIconIco.StaticContent)
 			systray.SetTemplateIcon(resourceIconIco.StaticContent, resourceIconIco.StaticContent)
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type PassKind int
+
+const (
+	// PassPattern repeats Pattern over the whole target.
+	PassPattern PassKind = iota
+	// PassRandom fills the target with random data.
+	PassRandom
+	// PassComplement writes the bitwise complement of the previous pass.
+	PassComplement
+	// PassDiscard asks the device to discard (TRIM) every block instead of
+	// writing to it; discarded blocks are expected to read back as zeroes.
+	PassDiscard
+	// PassSecureDiscard is PassDiscard with BLKSECDISCARD, which also erases
+	// old copies of the blocks the flash translation layer still holds.
+	PassSecureDiscard
+	// PassCryptoErase destroys the LUKS headers and keyslots of an encrypted
+	// device, leaving the ciphertext behind without any key to open it.
+	PassCryptoErase
+)
+
+type Pass struct {
+	Kind    PassKind
+	Pattern []byte
+	// Seed keys the random stream. Empty seeds are replaced by a fresh
+	// per-job seed before the pass is written.
+	Seed   string
+	Verify bool
+}
+
+func (p Pass) String() string {
+	switch p.Kind {
+	case PassRandom:
+		return "random"
+	case PassComplement:
+		return "complement"
+	case PassDiscard:
+		return "discard"
+	case PassSecureDiscard:
+		return "secure discard"
+	case PassCryptoErase:
+		return "crypto-erase"
+	default:
+		return fmt.Sprintf("0x%X", p.Pattern)
+	}
+}
+
+// generator returns a function that writes the bytes this pass puts at
+// offset off of the target into buf.
+func (p Pass) generator() (func(buf []byte, off uint64) error, error) {
+	switch p.Kind {
+	case PassPattern:
+		if len(p.Pattern) == 0 {
+			return nil, errors.New("pattern pass without a pattern")
+		}
+		return func(buf []byte, off uint64) error {
+			for i := range buf {
+				buf[i] = p.Pattern[(off+uint64(i))%uint64(len(p.Pattern))]
+			}
+			return nil
+		}, nil
+	case PassRandom:
+		if p.Seed == "" {
+			return nil, errors.New("random pass without a seed")
+		}
+		stream := newKeystream(p.Seed)
+		return func(buf []byte, off uint64) error {
+			stream.ReadAt(buf, off)
+			return nil
+		}, nil
+	case PassDiscard, PassSecureDiscard:
+		return func(buf []byte, off uint64) error {
+			clear(buf)
+			return nil
+		}, nil
+	case PassComplement:
+		return nil, errors.New("complement pass was not resolved against the previous pass")
+	case PassCryptoErase:
+		return nil, errors.New("crypto-erase passes are checked for surviving headers, not read back")
+	}
+	return nil, fmt.Errorf("unknown pass kind %d", p.Kind)
+}
+
+// resolvePasses turns every complement pass into the pattern pass it stands
+// for, so the engine only ever writes concrete patterns or random data.
+func resolvePasses(passes []Pass) ([]Pass, error) {
+	resolved := make([]Pass, len(passes))
+	for i, p := range passes {
+		if p.Kind != PassComplement {
+			resolved[i] = p
+			continue
+		}
+		if i == 0 {
+			return nil, errors.New("pass 1: complement needs a previous pass")
+		}
+		prev := resolved[i-1]
+		if prev.Kind != PassPattern {
+			return nil, fmt.Errorf("pass %d: cannot complement a %s pass", i+1, prev)
+		}
+		pattern := make([]byte, len(prev.Pattern))
+		for j, b := range prev.Pattern {
+			pattern[j] = ^b
+		}
+		resolved[i] = Pass{Kind: PassPattern, Pattern: pattern, Verify: p.Verify}
+	}
+	return resolved, nil
+}
+
+// WipeMethod describes a sequence of overwrite passes and the standard it
+// satisfies, so that results can cite it.
+type WipeMethod struct {
+	ID       string
+	Name     string
+	Standard string
+	Passes   []Pass
+	Verify   bool
+	// Supported reports why the method cannot be used on a target, if it can't.
+	Supported func(t WipeTarget) error
+}
+
+func (m *WipeMethod) Check(t WipeTarget) error {
+	if m.Supported == nil {
+		return nil
+	}
+	return m.Supported(t)
+}
+
+func patternPass(pattern ...byte) Pass {
+	return Pass{Kind: PassPattern, Pattern: pattern}
+}
+
+func randomPasses(n int) []Pass {
+	passes := make([]Pass, n)
+	for i := range passes {
+		passes[i] = randPass
+	}
+	return passes
+}
+
+func gutmannPasses() []Pass {
+	passes := randomPasses(4)
+	passes = append(passes,
+		patternPass(0x55),
+		patternPass(0xAA),
+		patternPass(0x92, 0x49, 0x24),
+		patternPass(0x49, 0x24, 0x92),
+		patternPass(0x24, 0x92, 0x49),
+	)
+	for b := 0x00; b <= 0xFF; b += 0x11 {
+		passes = append(passes, patternPass(byte(b)))
+	}
+	passes = append(passes,
+		patternPass(0x92, 0x49, 0x24),
+		patternPass(0x49, 0x24, 0x92),
+		patternPass(0x24, 0x92, 0x49),
+		patternPass(0x6D, 0xB6, 0xDB),
+		patternPass(0xB6, 0xDB, 0x6D),
+		patternPass(0xDB, 0x6D, 0xB6),
+	)
+	return append(passes, randomPasses(4)...)
+}
+
+var (
+	zeroPass = patternPass(0x00)
+	onesPass = patternPass(0xFF)
+	randPass = Pass{Kind: PassRandom}
+	compPass = Pass{Kind: PassComplement}
+
+	wipeMethods = []*WipeMethod{
+		{
+			ID:       "zero",
+			Name:     "Single zero pass",
+			Standard: "None",
+			Passes:   []Pass{zeroPass},
+		},
+		{
+			ID:       "random",
+			Name:     "Single random pass",
+			Standard: "None",
+			Passes:   []Pass{randPass},
+		},
+		{
+			ID:       "nist-clear",
+			Name:     "NIST 800-88 Clear",
+			Standard: "NIST SP 800-88 Rev. 1 Clear",
+			Passes:   []Pass{zeroPass},
+			Verify:   true,
+		},
+		{
+			// Overwriting from the host is a Clear technique however many
+			// passes it makes; Purge needs a sanitize command or crypto-erase.
+			ID:       "random-zero",
+			Name:     "Random + zero (2 passes)",
+			Standard: "NIST SP 800-88 Rev. 1 Clear",
+			Passes:   []Pass{randPass, zeroPass},
+			Verify:   true,
+		},
+		{
+			// Of the Purge techniques Wipr only has crypto-erase, so the
+			// profile is offered for devices encrypted with LUKS.
+			ID:        "nist-purge",
+			Name:      "NIST 800-88 Purge (LUKS crypto-erase)",
+			Standard:  "NIST SP 800-88 Rev. 1 Purge (cryptographic erase)",
+			Passes:    []Pass{{Kind: PassCryptoErase}},
+			Supported: luksSupported,
+		},
+		{
+			// Discarded blocks are only trusted once read back as zeroes:
+			// discard_zeroes_data has read 0 on every device since Linux
+			// 4.12, so no device can be taken at its word.
+			ID:        "discard",
+			Name:      "TRIM / discard + zero verify",
+			Standard:  "None",
+			Passes:    []Pass{{Kind: PassDiscard}},
+			Verify:    true,
+			Supported: discardSupported,
+		},
+		{
+			// Whether a secure discard erases the blocks physically is up to
+			// the firmware and nothing reports it, so it is not a Purge.
+			ID:        "secure-discard",
+			Name:      "Secure discard + zero verify",
+			Standard:  "None",
+			Passes:    []Pass{{Kind: PassSecureDiscard}},
+			Verify:    true,
+			Supported: discardSupported,
+		},
+		{
+			// Every pass-based method erases signatures first; this one stops there.
+			ID:       "signatures",
+			Name:     "Erase signatures only (quick disable)",
+			Standard: "None",
+		},
+		{
+			ID:       "dod-3",
+			Name:     "DoD 5220.22-M (3 passes)",
+			Standard: "US DoD 5220.22-M (E)",
+			Passes:   []Pass{zeroPass, compPass, randPass},
+			Verify:   true,
+		},
+		{
+			// Two runs of (E) around a single-character (C) pass.
+			ID:       "dod-7",
+			Name:     "DoD 5220.22-M ECE (7 passes)",
+			Standard: "US DoD 5220.22-M (ECE)",
+			Passes:   []Pass{zeroPass, compPass, randPass, patternPass(0x96), zeroPass, compPass, randPass},
+			Verify:   true,
+		},
+		{
+			ID:       "gutmann",
+			Name:     "Gutmann (35 passes)",
+			Standard: "Peter Gutmann, Secure Deletion of Data from Magnetic and Solid-State Memory (1996)",
+			Passes:   gutmannPasses(),
+		},
+		{
+			ID:       "schneier",
+			Name:     "Schneier (7 passes)",
+			Standard: "Bruce Schneier, Applied Cryptography (1996)",
+			Passes:   append([]Pass{onesPass, zeroPass}, randomPasses(5)...),
+		},
+		{
+			ID:       "vsitr",
+			Name:     "BSI VSITR (7 passes)",
+			Standard: "German BSI VSITR",
+			Passes:   []Pass{zeroPass, onesPass, zeroPass, onesPass, zeroPass, onesPass, patternPass(0xAA)},
+		},
+		{
+			ID:       "rcmp-tssit",
+			Name:     "RCMP TSSIT OPS-II (7 passes)",
+			Standard: "RCMP TSSIT OPS-II",
+			Passes:   []Pass{zeroPass, onesPass, zeroPass, onesPass, zeroPass, onesPass, randPass},
+			Verify:   true,
+		},
+		{
+			ID:       "gost",
+			Name:     "GOST R 50739-95 (2 passes)",
+			Standard: "GOST R 50739-95",
+			Passes:   []Pass{zeroPass, randPass},
+		},
+	}
+	defaultMethod = wipeMethods[0]
+)
+
+// discardSupported only allows discard methods on devices that advertise
+// discard.
+func discardSupported(t WipeTarget) error {
+	info, err := readDiscardInfo(t.Path)
+	if err != nil {
+		return err
+	}
+	if info.Granularity == 0 || info.MaxBytes == 0 {
+		return errors.New("device does not support discard")
+	}
+	return nil
+}
+
+func WipeMethods() []*WipeMethod {
+	return wipeMethods
+}
+
+func MethodByID(id string) *WipeMethod {
+	for _, m := range wipeMethods {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func MethodByName(name string) *WipeMethod {
+	for _, m := range wipeMethods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func methodNames() []string {
+	names := []string{}
+	for _, m := range wipeMethods {
+		names = append(names, m.Name)
+	}
+	return names
+}
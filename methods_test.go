@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// passBytes is what a pass writes at the start of a target, and from an
+// offset that does not fall on a pattern boundary.
+func passBytes(t *testing.T, p Pass, off uint64) []byte {
+	t.Helper()
+	gen, err := p.generator()
+	if err != nil {
+		t.Fatalf("%s: %v", p, err)
+	}
+	buf := make([]byte, 24)
+	if err := gen(buf, off); err != nil {
+		t.Fatalf("%s: %v", p, err)
+	}
+	return buf
+}
+
+func repeatPattern(pattern []byte, off uint64, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = pattern[(off+uint64(i))%uint64(len(pattern))]
+	}
+	return out
+}
+
+func TestMethodPasses(t *testing.T) {
+	// nil stands for a random pass.
+	p := func(b ...byte) []byte { return b }
+	gutmann := [][]byte{nil, nil, nil, nil, p(0x55), p(0xAA), p(0x92, 0x49, 0x24), p(0x49, 0x24, 0x92), p(0x24, 0x92, 0x49)}
+	for b := 0x00; b <= 0xFF; b += 0x11 {
+		gutmann = append(gutmann, p(byte(b)))
+	}
+	gutmann = append(gutmann, p(0x92, 0x49, 0x24), p(0x49, 0x24, 0x92), p(0x24, 0x92, 0x49),
+		p(0x6D, 0xB6, 0xDB), p(0xB6, 0xDB, 0x6D), p(0xDB, 0x6D, 0xB6), nil, nil, nil, nil)
+
+	tests := []struct {
+		id     string
+		passes [][]byte
+	}{
+		{"dod-3", [][]byte{p(0x00), p(0xFF), nil}},
+		{"dod-7", [][]byte{p(0x00), p(0xFF), nil, p(0x96), p(0x00), p(0xFF), nil}},
+		{"gutmann", gutmann},
+		{"schneier", [][]byte{p(0xFF), p(0x00), nil, nil, nil, nil, nil}},
+		{"vsitr", [][]byte{p(0x00), p(0xFF), p(0x00), p(0xFF), p(0x00), p(0xFF), p(0xAA)}},
+		{"rcmp-tssit", [][]byte{p(0x00), p(0xFF), p(0x00), p(0xFF), p(0x00), p(0xFF), nil}},
+		{"gost", [][]byte{p(0x00), nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			m := MethodByID(tt.id)
+			if m == nil {
+				t.Fatal("method not registered")
+			}
+			passes, err := resolvePasses(m.Passes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(passes) != len(tt.passes) {
+				t.Fatalf("got %d passes, want %d", len(passes), len(tt.passes))
+			}
+			for i, pass := range passes {
+				want := tt.passes[i]
+				if want == nil {
+					if pass.Kind != PassRandom {
+						t.Errorf("pass %d: got %s, want random", i+1, pass)
+						continue
+					}
+					pass.Seed = fmt.Sprintf("%s-%d", tt.id, i)
+					stream := make([]byte, 24+5)
+					newKeystream(pass.Seed).ReadAt(stream, 0)
+					if got := passBytes(t, pass, 0); !bytes.Equal(got, stream[:24]) {
+						t.Errorf("pass %d: got % X, want % X", i+1, got, stream[:24])
+					}
+					if got := passBytes(t, pass, 5); !bytes.Equal(got, stream[5:]) {
+						t.Errorf("pass %d at offset 5: got % X, want % X", i+1, got, stream[5:])
+					}
+					continue
+				}
+				if pass.Kind != PassPattern {
+					t.Errorf("pass %d: got %s, want 0x%X", i+1, pass, want)
+					continue
+				}
+				for _, off := range []uint64{0, 5} {
+					if got, exp := passBytes(t, pass, off), repeatPattern(want, off, 24); !bytes.Equal(got, exp) {
+						t.Errorf("pass %d at offset %d: got % X, want % X", i+1, off, got, exp)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResolveComplement(t *testing.T) {
+	passes, err := resolvePasses([]Pass{patternPass(0x92, 0x49, 0x24), {Kind: PassComplement, Verify: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := passes[1]; got.Kind != PassPattern || !bytes.Equal(got.Pattern, []byte{0x6D, 0xB6, 0xDB}) || !got.Verify {
+		t.Errorf("complement resolved to %s (verify %v), want 0x6DB6DB (verify true)", got, got.Verify)
+	}
+	// The complement of the pass before must not change it.
+	if got := passes[0].Pattern; !bytes.Equal(got, []byte{0x92, 0x49, 0x24}) {
+		t.Errorf("previous pass changed to 0x%X", got)
+	}
+
+	for _, bad := range [][]Pass{
+		{compPass},
+		{randPass, compPass},
+	} {
+		if _, err := resolvePasses(bad); err == nil {
+			t.Errorf("%v: resolved without an error", bad)
+		}
+	}
+	if _, err := compPass.generator(); err == nil {
+		t.Error("unresolved complement pass has a generator")
+	}
+}
+
+func TestPurgeProfile(t *testing.T) {
+	m := MethodByID("nist-purge")
+	if m == nil {
+		t.Fatal("method not registered")
+	}
+	if !strings.Contains(m.Standard, "Purge") {
+		t.Errorf("standard is %q", m.Standard)
+	}
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.img")
+	luks := filepath.Join(dir, "luks.img")
+	img := make([]byte, 64<<10)
+	if err := os.WriteFile(plain, img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	copy(img, luks1Header(128))
+	if err := os.WriteFile(luks, img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Check(WipeTarget{Path: plain, Size: uint64(len(img))}); err == nil {
+		t.Error("offered for a device without LUKS")
+	}
+	if err := m.Check(WipeTarget{Path: luks, Size: uint64(len(img))}); err != nil {
+		t.Errorf("not offered for a LUKS device: %v", err)
+	}
+	if got := suggestedMethod(WipeTarget{Path: luks, Size: uint64(len(img))}); got != m {
+		t.Errorf("suggested %s for a LUKS device", got.ID)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	errMounted    = errors.New("target is in use")
+	errNotMounted = errors.New("not a mounted filesystem")
+)
+
+// targetMount is a mount or active swap area that uses one of a target's
+// devices, directly or through a filesystem nested under one of its mounts.
+type targetMount struct {
+	Device     string
+	MountPoint string
+	Swap       bool
+}
+
+func (m targetMount) String() string {
+	if m.Swap {
+		return "swap " + m.MountPoint + " on " + m.Device
+	}
+	return m.MountPoint + " (" + m.Device + ")"
+}
+
+func mountList(mounts []targetMount) string {
+	list := []string{}
+	for _, m := range mounts {
+		list = append(list, m.String())
+	}
+	return strings.Join(list, ", ")
+}
+
+// heldDevice is a device kept open, and on Windows locked, for the length of
+// a job.
+type heldDevice struct {
+	f    *os.File
+	size uint64
+}
+
+func releaseDevices(held map[string]heldDevice) {
+	for _, h := range held {
+		h.f.Close()
+	}
+}
+
+// prepareMounts refuses a target that is mounted or used as swap unless the
+// job allows unmounting it, in which case everything using it is unmounted
+// first.
+func prepareMounts(t WipeTarget, unmount bool) (map[string]heldDevice, error) {
+	mounts, err := targetMounts(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+	if !unmount {
+		return nil, fmt.Errorf("%s: %w: %s", t.Path, errMounted, mountList(mounts))
+	}
+	return unmountTarget(t, mounts)
+}
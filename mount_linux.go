@@ -0,0 +1,170 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// targetMounts finds every mount and swap area on the target, its
+// partitions or the members of its stack, including those on device-mapper
+// or md devices stacked on them, bind mounts of them and mounts nested
+// below their mount points.
+func targetMounts(t WipeTarget) ([]targetMount, error) {
+	devices := map[string]bool{}
+	for _, target := range t.devices() {
+		devices[target.Path] = true
+	}
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	// uses reports which of the target's devices the device numbered dev
+	// sits on, if any.
+	uses := func(dev string) (string, bool) {
+		name, ok := resolveBlockDev(dev, mounts)
+		if !ok {
+			return "", false
+		}
+		for _, n := range blockStack(name) {
+			if devices["/dev/"+n] {
+				return "/dev/" + n, true
+			}
+		}
+		return "", false
+	}
+
+	found := []targetMount{}
+	swaps, err := activeSwaps()
+	if err != nil {
+		return nil, err
+	}
+	for _, swap := range swaps {
+		dev, err := deviceNumber(swap)
+		if err != nil {
+			dev, err = fileDeviceNumber(swap)
+		}
+		if err != nil {
+			continue
+		}
+		if device, ok := uses(dev); ok {
+			found = append(found, targetMount{Device: device, MountPoint: swap, Swap: true})
+		}
+	}
+
+	direct := []string{}
+	for _, m := range mounts {
+		if device, ok := uses(m.Dev); ok {
+			found = append(found, targetMount{Device: device, MountPoint: m.MountPoint})
+			direct = append(direct, m.MountPoint)
+		}
+	}
+	for _, m := range mounts {
+		for _, mp := range direct {
+			if m.MountPoint != mp && strings.HasPrefix(m.MountPoint, strings.TrimSuffix(mp, "/")+"/") && !hasMount(found, m.MountPoint) {
+				found = append(found, targetMount{Device: m.Source, MountPoint: m.MountPoint})
+			}
+		}
+	}
+	return found, nil
+}
+
+func hasMount(mounts []targetMount, mountPoint string) bool {
+	for _, m := range mounts {
+		if !m.Swap && m.MountPoint == mountPoint {
+			return true
+		}
+	}
+	return false
+}
+
+// unmountTarget turns off swap on the target, then unmounts the deepest
+// mount points first so nested mounts never hold their parents busy. It
+// fails rather than detaching lazily, and checks nothing is left mounted.
+func unmountTarget(t WipeTarget, mounts []targetMount) (map[string]heldDevice, error) {
+	for _, m := range mounts {
+		if m.Swap {
+			if err := swapoff(m.MountPoint); err != nil {
+				return nil, fmt.Errorf("swapoff %s: %w", m.MountPoint, err)
+			}
+		}
+	}
+	points := []string{}
+	for _, m := range mounts {
+		if !m.Swap {
+			points = append(points, m.MountPoint)
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return strings.Count(points[i], "/") > strings.Count(points[j], "/")
+	})
+	for _, mp := range points {
+		// EINVAL means it is no longer a mount point, which is what we want.
+		if err := unix.Unmount(mp, 0); err != nil && err != unix.EINVAL {
+			return nil, fmt.Errorf("unmount %s: %w", mp, err)
+		}
+	}
+	remaining, err := targetMounts(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("%s: %w after unmounting: %s", t.Path, errMounted, mountList(remaining))
+	}
+	return nil, nil
+}
+
+func swapoff(path string) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_SWAPOFF, uintptr(unsafe.Pointer(p)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func activeSwaps() ([]string, error) {
+	f, err := os.Open("/proc/swaps")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	swaps := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			swaps = append(swaps, unescapeMountField(fields[0]))
+		}
+	}
+	return swaps, scanner.Err()
+}
+
+// checkMountPoint makes sure path is where a filesystem is mounted, so an
+// unmounted partition is never mistaken for the root filesystem.
+func checkMountPoint(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: no mount point", errNotMounted)
+	}
+	clean := filepath.Clean(path)
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if m.MountPoint == clean {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", path, errNotMounted)
+}
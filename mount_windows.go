@@ -0,0 +1,50 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// targetMounts lists the volumes with a drive letter on the target.
+func targetMounts(t WipeTarget) ([]targetMount, error) {
+	found := []targetMount{}
+	for _, target := range t.devices() {
+		if letter := strings.TrimPrefix(target.Path, `\\.\`); letter != target.Path && strings.HasSuffix(letter, ":") {
+			found = append(found, targetMount{Device: target.Path, MountPoint: letter + `\`})
+		}
+	}
+	return found, nil
+}
+
+// unmountTarget locks and dismounts the volumes on a disk. Windows keeps a
+// volume dismounted only while the handle holding its lock stays open, so
+// they are returned to be closed when the job ends. The target's own
+// volume is locked when the engine opens it.
+func unmountTarget(t WipeTarget, mounts []targetMount) (map[string]heldDevice, error) {
+	held := map[string]heldDevice{}
+	for _, m := range mounts {
+		if m.Device == t.Path {
+			continue
+		}
+		f, size, err := openDevice(m.Device)
+		if err != nil {
+			releaseDevices(held)
+			return nil, fmt.Errorf("unmount %s: %w", m.MountPoint, err)
+		}
+		held[m.Device] = heldDevice{f: f, size: size}
+	}
+	return held, nil
+}
+
+// checkMountPoint makes sure path is the root of a drive, so an empty or
+// relative path is never taken for a filesystem to fill.
+func checkMountPoint(path string) error {
+	volume := filepath.VolumeName(path)
+	if volume == "" || strings.TrimRight(path[len(volume):], `\/`) != "" {
+		return fmt.Errorf("%q: %w", path, errNotMounted)
+	}
+	return nil
+}
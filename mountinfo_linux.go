@@ -0,0 +1,101 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// mountEntry is one line of /proc/self/mountinfo.
+type mountEntry struct {
+	ID         int
+	Parent     int
+	Dev        string
+	Root       string
+	MountPoint string
+	FSType     string
+	Source     string
+}
+
+func readMountInfo() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// parseMountInfo parses the mountinfo format described in proc(5).
+func parseMountInfo(r io.Reader) ([]mountEntry, error) {
+	mounts := []mountEntry{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Optional fields end at a lone "-", followed by type and source.
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			return nil, fmt.Errorf("malformed mountinfo line %q", scanner.Text())
+		}
+		id, _ := strconv.Atoi(fields[0])
+		parent, _ := strconv.Atoi(fields[1])
+		mounts = append(mounts, mountEntry{
+			ID:         id,
+			Parent:     parent,
+			Dev:        fields[2],
+			Root:       unescapeMountField(fields[3]),
+			MountPoint: unescapeMountField(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountField(fields[sep+2]),
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes (\040 for a space) the kernel
+// uses for whitespace and backslashes in mountinfo.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// resolveBlockDev returns the kernel name of the block device numbered dev.
+// Filesystems such as btrfs report an anonymous device number instead, in
+// which case the source of the mount with that number names the real one.
+func resolveBlockDev(dev string, mounts []mountEntry) (string, bool) {
+	if name, ok := blockNameOf(dev); ok {
+		return name, true
+	}
+	for _, m := range mounts {
+		if m.Dev == dev && strings.HasPrefix(m.Source, "/dev/") {
+			if source, err := deviceNumber(m.Source); err == nil && source != dev {
+				return blockNameOf(source)
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	const mountinfo = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+40 22 8:3 / /mnt/My\040Disk rw,relatime - vfat /dev/sdb1 rw,fmask=0022
+41 22 8:2 /srv/back\134slash /srv/tab\011name rw shared:3 master:2 propagate_from:1 unbindable - ext4 /dev/sda2 rw
+42 22 0:45 / /var/lib/docker rw,relatime - btrfs /dev/mapper/vg-data\040vol rw,space_cache=v2
+`
+	mounts, err := parseMountInfo(strings.NewReader(mountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mountEntry{
+		{ID: 22, Parent: 1, Dev: "8:2", Root: "/", MountPoint: "/", FSType: "ext4", Source: "/dev/sda2"},
+		{ID: 23, Parent: 22, Dev: "0:21", Root: "/", MountPoint: "/proc", FSType: "proc", Source: "proc"},
+		{ID: 40, Parent: 22, Dev: "8:3", Root: "/", MountPoint: "/mnt/My Disk", FSType: "vfat", Source: "/dev/sdb1"},
+		{ID: 41, Parent: 22, Dev: "8:2", Root: `/srv/back\slash`, MountPoint: "/srv/tab\tname", FSType: "ext4", Source: "/dev/sda2"},
+		{ID: 42, Parent: 22, Dev: "0:45", Root: "/", MountPoint: "/var/lib/docker", FSType: "btrfs", Source: "/dev/mapper/vg-data vol"},
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("got %+v\nwant %+v", mounts, want)
+	}
+
+	for _, line := range []string{
+		"22 1 8:2 / / rw,relatime shared:1 ext4 /dev/sda2 rw",
+		"22 1 8:2 / / rw - ext4",
+		"22 1 8:2 / -",
+	} {
+		if _, err := parseMountInfo(strings.NewReader(line + "\n")); err == nil {
+			t.Errorf("%q: parsed without an error", line)
+		}
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	for in, want := range map[string]string{
+		"/mnt/plain":         "/mnt/plain",
+		`/mnt/a\040b`:        "/mnt/a b",
+		`\040lead`:           " lead",
+		`trail\040`:          "trail ",
+		`/a\011b\012c\134d`:  "/a\tb\nc\\d",
+		`/mnt/\040\040`:      "/mnt/  ",
+		`/bad\04`:            `/bad\04`,
+		`/bad\999x`:          `/bad\999x`,
+		`/overflow\777`:      `/overflow\777`,
+		`/not\escape\`:       `/not\escape\`,
+		`/utf8\303\251t\303`: "/utf8ét\xc3",
+	} {
+		if got := unescapeMountField(in); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// The mount table of the running system parses, and has a root.
+func TestReadMountInfo(t *testing.T) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range mounts {
+		if m.MountPoint == "/" {
+			return
+		}
+	}
+	t.Errorf("no root mount in %+v", mounts)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"image/color"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	barHeight = 48
+	// minSegmentWidth keeps tiny partitions, such as a BIOS boot partition
+	// next to a terabyte of data, wide enough to see and click.
+	minSegmentWidth = 24
+	// Gaps up to alignmentGap are left for alignment and not shown.
+	alignmentGap = 1 << 20
+)
+
+// Filesystem colours, as GParted uses them.
+var (
+	unallocatedColor = color.NRGBA{0xA9, 0xA9, 0xA9, 0xFF}
+	unknownColor     = color.NRGBA{0x00, 0x00, 0x00, 0xFF}
+	fsColors         = map[string]color.NRGBA{
+		"ext2":              {0x9D, 0xB8, 0xD2, 0xFF},
+		"ext3":              {0x75, 0x90, 0xAE, 0xFF},
+		"ext4":              {0x4B, 0x69, 0x83, 0xFF},
+		"xfs":               {0xEE, 0xD6, 0x80, 0xFF},
+		"btrfs":             {0xFF, 0x99, 0x55, 0xFF},
+		"ntfs":              {0x42, 0xE5, 0xAC, 0xFF},
+		"vfat":              {0x18, 0xD9, 0x18, 0xFF},
+		"fat12":             {0x00, 0xFF, 0x00, 0xFF},
+		"fat16":             {0x00, 0xFF, 0x00, 0xFF},
+		"fat32":             {0x18, 0xD9, 0x18, 0xFF},
+		"exfat":             {0x2E, 0x8B, 0x57, 0xFF},
+		"swap":              {0xC1, 0x66, 0x5A, 0xFF},
+		"crypto_luks":       {0x62, 0x5B, 0x81, 0xFF},
+		"lvm2_member":       {0xCC, 0x99, 0x66, 0xFF},
+		"linux_raid_member": {0x89, 0x65, 0xC2, 0xFF},
+	}
+)
+
+func fsColor(fsType string) color.NRGBA {
+	if c, ok := fsColors[strings.ToLower(fsType)]; ok {
+		return c
+	}
+	return unknownColor
+}
+
+// barSegment is one partition, or a stretch of unallocated space, in the
+// partition bar.
+type barSegment struct {
+	widget.BaseWidget
+	ID       string
+	Bytes    uint64
+	Text     string
+	Color    color.Color
+	Selected bool
+	OnTapped func()
+}
+
+func newBarSegment(id string, size uint64, text string, c color.Color) *barSegment {
+	s := &barSegment{ID: id, Bytes: size, Text: text, Color: c}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *barSegment) CreateRenderer() fyne.WidgetRenderer {
+	frame := canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
+	frame.StrokeColor = s.Color
+	frame.StrokeWidth = 2
+	if s.Selected {
+		frame.StrokeColor = theme.Color(theme.ColorNamePrimary)
+		frame.StrokeWidth = 3
+	}
+	strip := canvas.NewRectangle(s.Color)
+	strip.SetMinSize(fyne.NewSize(0, 8))
+	label := widget.NewLabel(s.Text)
+	label.Truncation = fyne.TextTruncateEllipsis
+	label.SizeName = theme.SizeNameCaptionText
+	return widget.NewSimpleRenderer(container.NewStack(frame, container.NewBorder(strip, nil, nil, nil, label)))
+}
+
+func (s *barSegment) Tapped(*fyne.PointEvent) {
+	if s.OnTapped != nil {
+		s.OnTapped()
+	}
+}
+
+// barLayout gives every segment minSegmentWidth and shares the rest of the
+// width out in proportion to their sizes.
+type barLayout struct{}
+
+func (barLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	var total uint64
+	for _, o := range objects {
+		total += o.(*barSegment).Bytes
+	}
+	spare := size.Width - float32(len(objects))*minSegmentWidth
+	x := float32(0)
+	for _, o := range objects {
+		w := size.Width / float32(len(objects))
+		if spare > 0 && total > 0 {
+			w = minSegmentWidth + spare*float32(float64(o.(*barSegment).Bytes)/float64(total))
+		}
+		o.Move(fyne.NewPos(x, 0))
+		o.Resize(fyne.NewSize(w, size.Height))
+		x += w
+	}
+}
+
+func (barLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	return fyne.NewSize(float32(len(objects))*minSegmentWidth, barHeight)
+}
+
+// diskLayout is a disk, or image file, and its partitions with the IDs they
+// have in the target list.
+type diskLayout struct {
+	Disk  WipeTarget
+	IDs   []string
+	Parts []WipeTarget
+}
+
+// partitionBar draws the selected disk as a horizontal bar, GParted style:
+// one segment per partition, sized to scale and coloured by filesystem,
+// with the unallocated space between them in grey.
+type partitionBar struct {
+	bar     *fyne.Container
+	caption *widget.Label
+	box     *fyne.Container
+	// OnTapped is called with the tree ID of a partition when its segment
+	// is clicked.
+	OnTapped func(id string)
+}
+
+func newPartitionBar() *partitionBar {
+	b := &partitionBar{
+		bar:     container.New(barLayout{}),
+		caption: widget.NewLabel(""),
+	}
+	b.box = container.NewVBox(b.bar, b.caption)
+	b.Show(diskLayout{}, "")
+	return b
+}
+
+// Show draws a disk and its partitions, highlighting the one with the ID
+// selected, or hides the bar when there is no disk.
+func (b *partitionBar) Show(layout diskLayout, selected string) {
+	b.bar.RemoveAll()
+	disk := layout.Disk
+	if disk.Path == "" {
+		b.box.Hide()
+		return
+	}
+	type placed struct {
+		id       string
+		target   WipeTarget
+		start    uint64
+		hasStart bool
+	}
+	list := []placed{}
+	for i, target := range layout.Parts {
+		start, ok := target.Offset, target.Offset > 0
+		if !ok {
+			start, ok = partitionStart(target.Path)
+		}
+		list = append(list, placed{layout.IDs[i], target, start, ok})
+	}
+	// Without every start offset the gaps cannot be placed; the partitions
+	// are then drawn in the order they are listed.
+	known := true
+	for _, p := range list {
+		known = known && p.hasStart
+	}
+	if known {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].start < list[j].start })
+	}
+	gap := func(size uint64) {
+		if size > alignmentGap {
+			b.bar.Add(newBarSegment("", size, "unallocated "+formatBytes(size), unallocatedColor))
+		}
+	}
+	var end uint64
+	for _, p := range list {
+		if known && p.start > end {
+			gap(p.start - end)
+		}
+		text := partitionName(p.target) + " " + formatBytes(p.target.Size)
+		if p.target.FSType != "" {
+			text = partitionName(p.target) + " " + fsTypeName(p.target.FSType, p.target.FSVersion) + " " + formatBytes(p.target.Size)
+		}
+		segment := newBarSegment(p.id, p.target.Size, text, fsColor(p.target.FSType))
+		segment.Selected = p.id == selected
+		id := p.id
+		segment.OnTapped = func() {
+			if b.OnTapped != nil {
+				b.OnTapped(id)
+			}
+		}
+		b.bar.Add(segment)
+		end = max(end, p.start+p.target.Size)
+	}
+	if known && disk.Size > end {
+		gap(disk.Size - end)
+	}
+	if len(list) == 0 {
+		b.bar.Add(newBarSegment("", disk.Size, "unallocated "+formatBytes(disk.Size), unallocatedColor))
+	}
+	b.caption.SetText(disk.Path + ": click a partition to select it")
+	b.box.Show()
+	b.bar.Refresh()
+}
+
+// Container returns the bar with its caption below.
+func (b *partitionBar) Container() fyne.CanvasObject {
+	return b.box
+}
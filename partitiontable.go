@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	errNoPartitionTable = errors.New("no partition table")
+	errTableRemains     = errors.New("partition table remains after wiping")
+)
+
+const (
+	// maxLogical bounds the chain of extended boot records, which a
+	// damaged table could make loop.
+	maxLogical = 128
+	// maxGPTEntries is far more than any real table holds.
+	maxGPTEntries = 4096
+)
+
+// PartitionTable is a GPT or MBR partition table as read from the device
+// itself, without relying on the kernel having parsed it. Problems lists the
+// damage found and worked around, such as a corrupt primary GPT header.
+type PartitionTable struct {
+	Scheme     string
+	SectorSize uint64
+	DiskID     string
+	Hybrid     bool
+	Partitions []TablePartition
+	Problems   []string
+}
+
+func (t *PartitionTable) String() string {
+	s := fmt.Sprintf("%s with %d partitions", t.Scheme, len(t.Partitions))
+	if t.Hybrid {
+		s += " and a hybrid MBR"
+	}
+	return s
+}
+
+// TablePartition is an entry of a partition table. Start and Size are in
+// bytes. Type is the type GUID on GPT and the type byte on MBR; UUID is the
+// PARTUUID the kernel would give the partition.
+type TablePartition struct {
+	Number   int
+	Start    uint64
+	Size     uint64
+	Type     string
+	TypeName string
+	UUID     string
+	Name     string
+	Bootable bool
+	Logical  bool
+}
+
+var gptTypeNames = map[string]string{
+	"C12A7328-F81F-11D2-BA4B-00A0C93EC93B": "EFI system",
+	"21686148-6449-6E6F-744E-656564454649": "BIOS boot",
+	"0FC63DAF-8483-4772-8E79-3D69D8477DE4": "Linux filesystem",
+	"0657FD6D-A4AB-43C4-84E5-0933C84B4F4F": "Linux swap",
+	"E6D6D379-F507-44C2-A23C-238F2A3DF928": "Linux LVM",
+	"A19D880F-05FC-4D3B-A006-743F0F84911E": "Linux RAID",
+	"CA7D7CCB-63ED-4C53-861C-1742536059CC": "Linux LUKS",
+	"4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709": "Linux root (x86-64)",
+	"BC13C2FF-59E6-4262-A352-B275FD6F7172": "Linux extended boot",
+	"933AC7E1-2EB4-4F13-B844-0E14E2AEF915": "Linux home",
+	"EBD0A0A2-B9E5-4433-87C0-68B6B72699C7": "Microsoft basic data",
+	"E3C9E316-0B5C-4DB8-817D-F92DF00215AE": "Microsoft reserved",
+	"DE94BBA4-06D1-4D40-A16A-BFD50179D6AC": "Windows recovery",
+	"48465300-0000-11AA-AA11-00306543ECAC": "Apple HFS+",
+	"7C3457EF-0000-11AA-AA11-00306543ECAC": "Apple APFS",
+}
+
+var mbrTypeNames = map[byte]string{
+	0x01: "FAT12",
+	0x04: "FAT16 <32M",
+	0x05: "Extended",
+	0x06: "FAT16",
+	0x07: "HPFS/NTFS/exFAT",
+	0x0B: "W95 FAT32",
+	0x0C: "W95 FAT32 (LBA)",
+	0x0E: "W95 FAT16 (LBA)",
+	0x0F: "W95 Extended (LBA)",
+	0x27: "Hidden NTFS WinRE",
+	0x82: "Linux swap",
+	0x83: "Linux",
+	0x85: "Linux extended",
+	0x8E: "Linux LVM",
+	0xA5: "FreeBSD",
+	0xAF: "HFS/HFS+",
+	0xEE: "GPT",
+	0xEF: "EFI (FAT-12/16/32)",
+	0xFD: "Linux raid autodetect",
+}
+
+func isExtended(typ byte) bool {
+	return typ == 0x05 || typ == 0x0F || typ == 0x85
+}
+
+// guidString formats a GUID stored in the mixed-endian layout GPT uses.
+func guidString(b []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10], b[10:16])
+}
+
+type tableReader struct {
+	r    io.ReaderAt
+	size uint64
+}
+
+func (t tableReader) read(off uint64, n int) ([]byte, error) {
+	if off > t.size || uint64(n) > t.size-off {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	if read, err := t.r.ReadAt(buf, int64(off)); err != nil && !(errors.Is(err, io.EOF) && read == n) {
+		return nil, fmt.Errorf("read at offset %d: %w", off, err)
+	}
+	return buf, nil
+}
+
+// readPartitionTable reads the GPT or MBR partition table on r, which is
+// size bytes long. A GPT whose primary header or entries are damaged is
+// read from its backup at the end of the device, and the other way round.
+// MBR tables are read with 512-byte sectors.
+func readPartitionTable(r io.ReaderAt, size uint64) (*PartitionTable, error) {
+	tr := tableReader{r: r, size: size}
+	mbr, err := tr.read(0, 512)
+	if err != nil {
+		return nil, err
+	}
+	var entries [][]byte
+	protective := false
+	if isPartitionMBR(mbr) {
+		for i := 0; i < 4; i++ {
+			entry := mbr[446+16*i : 462+16*i]
+			entries = append(entries, entry)
+			protective = protective || entry[4] == 0xEE
+		}
+	}
+	for _, sector := range []uint64{512, 4096} {
+		table, err := tr.readGPT(sector)
+		if err != nil {
+			return nil, err
+		}
+		if table == nil {
+			continue
+		}
+		if !protective {
+			table.Problems = append(table.Problems, "protective MBR is missing")
+		}
+		for _, entry := range entries {
+			if entry[4] != 0x00 && entry[4] != 0xEE {
+				table.Hybrid = true
+			}
+		}
+		return table, nil
+	}
+	if entries == nil {
+		return nil, errNoPartitionTable
+	}
+	if protective {
+		return nil, fmt.Errorf("%w: protective MBR without a readable GPT", errNoPartitionTable)
+	}
+	return tr.readMBR(mbr)
+}
+
+// isPartitionMBR reports whether b is a boot sector with a partition table,
+// rather than the boot sector of a filesystem or random data.
+func isPartitionMBR(b []byte) bool {
+	if b == nil || !bytes.Equal(b[510:], bootSignature) {
+		return false
+	}
+	if isFATBootSector(b) || bytes.Equal(b[3:11], []byte("NTFS    ")) || bytes.Equal(b[3:11], []byte("EXFAT   ")) {
+		return false
+	}
+	used := false
+	for i := 0; i < 4; i++ {
+		entry := b[446+16*i:]
+		if entry[0] != 0x00 && entry[0] != 0x80 {
+			return false
+		}
+		used = used || entry[4] != 0x00
+	}
+	return used
+}
+
+// readGPT reads the GPT for the given sector size, preferring the primary
+// header and falling back to the backup.
+func (t tableReader) readGPT(sector uint64) (*PartitionTable, error) {
+	if t.size < 3*sector {
+		return nil, nil
+	}
+	primary, primaryErr, err := t.gptHeader(sector, sector)
+	if err != nil {
+		return nil, err
+	}
+	backupOff := t.size/sector*sector - sector
+	if primary != nil {
+		if lba := binary.LittleEndian.Uint64(primary[32:40]); lba > 1 && lba < t.size/sector {
+			backupOff = lba * sector
+		}
+	}
+	backup, backupErr, err := t.gptHeader(backupOff, sector)
+	if err != nil {
+		return nil, err
+	}
+	var table *PartitionTable
+	if primary != nil {
+		if table, err = t.gptEntries(primary, sector); err != nil {
+			return nil, err
+		}
+		if table == nil {
+			primaryErr = "entries checksum mismatch"
+		} else if backup == nil {
+			table.Problems = append(table.Problems, "backup GPT: "+backupErr)
+		}
+	}
+	if table == nil && backup != nil {
+		if table, err = t.gptEntries(backup, sector); err != nil {
+			return nil, err
+		}
+		if table != nil {
+			table.Problems = append(table.Problems, "primary GPT: "+primaryErr+"; read the backup instead")
+		}
+	}
+	return table, nil
+}
+
+// gptHeader returns the GPT header at off if its magic and checksum are
+// right, or why it is not usable.
+func (t tableReader) gptHeader(off, sector uint64) ([]byte, string, error) {
+	hdr, err := t.read(off, int(sector))
+	if hdr == nil || err != nil {
+		return nil, "missing", err
+	}
+	if !bytes.Equal(hdr[:8], gptMagic) {
+		return nil, "missing", nil
+	}
+	hdrSize := binary.LittleEndian.Uint32(hdr[12:16])
+	if hdrSize < 92 || uint64(hdrSize) > sector {
+		return nil, "bad header size", nil
+	}
+	crc := binary.LittleEndian.Uint32(hdr[16:20])
+	check := append([]byte{}, hdr[:hdrSize]...)
+	clear(check[16:20])
+	if crc32.ChecksumIEEE(check) != crc {
+		return nil, "header checksum mismatch", nil
+	}
+	return hdr, "", nil
+}
+
+// gptEntries reads the partition entries a GPT header points to.
+func (t tableReader) gptEntries(hdr []byte, sector uint64) (*PartitionTable, error) {
+	start := binary.LittleEndian.Uint64(hdr[72:80]) * sector
+	count := binary.LittleEndian.Uint32(hdr[80:84])
+	entrySize := binary.LittleEndian.Uint32(hdr[84:88])
+	if count > maxGPTEntries || entrySize < 128 || entrySize > 1024 {
+		return nil, nil
+	}
+	array, err := t.read(start, int(count*entrySize))
+	if array == nil || err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(array) != binary.LittleEndian.Uint32(hdr[88:92]) {
+		return nil, nil
+	}
+	table := &PartitionTable{Scheme: "gpt", SectorSize: sector, DiskID: guidString(hdr[56:72])}
+	for i := uint32(0); i < count; i++ {
+		e := array[i*entrySize : (i+1)*entrySize]
+		if bytes.Equal(e[:16], make([]byte, 16)) {
+			continue
+		}
+		first := binary.LittleEndian.Uint64(e[32:40])
+		last := binary.LittleEndian.Uint64(e[40:48])
+		if last < first {
+			table.Problems = append(table.Problems, fmt.Sprintf("partition %d ends before it starts", i+1))
+			continue
+		}
+		name := make([]uint16, 36)
+		for j := range name {
+			name[j] = binary.LittleEndian.Uint16(e[56+2*j:])
+		}
+		typ := guidString(e[:16])
+		p := TablePartition{
+			Number:   int(i + 1),
+			Start:    first * sector,
+			Size:     (last - first + 1) * sector,
+			Type:     typ,
+			TypeName: gptTypeNames[typ],
+			UUID:     strings.ToLower(guidString(e[16:32])),
+			Name:     strings.TrimRight(string(utf16.Decode(name)), "\x00"),
+			// Legacy BIOS bootable attribute.
+			Bootable: binary.LittleEndian.Uint64(e[48:56])&0x4 != 0,
+		}
+		table.add(p, t.size)
+	}
+	return table, nil
+}
+
+// readMBR reads the primary partitions of an MBR and the logical
+// partitions in its extended partition.
+func (t tableReader) readMBR(mbr []byte) (*PartitionTable, error) {
+	const sector = 512
+	diskID := binary.LittleEndian.Uint32(mbr[440:444])
+	table := &PartitionTable{Scheme: "dos", SectorSize: sector, DiskID: fmt.Sprintf("0x%08x", diskID)}
+	uuid := func(n int) string { return fmt.Sprintf("%08x-%02x", diskID, n) }
+	var extended uint64
+	for i := 0; i < 4; i++ {
+		e := mbr[446+16*i : 462+16*i]
+		start := uint64(binary.LittleEndian.Uint32(e[8:12]))
+		count := uint64(binary.LittleEndian.Uint32(e[12:16]))
+		if e[4] == 0x00 || count == 0 {
+			continue
+		}
+		if isExtended(e[4]) {
+			if extended != 0 {
+				table.Problems = append(table.Problems, "more than one extended partition")
+				continue
+			}
+			extended = start
+		}
+		table.add(TablePartition{
+			Number:   i + 1,
+			Start:    start * sector,
+			Size:     count * sector,
+			Type:     fmt.Sprintf("0x%02x", e[4]),
+			TypeName: mbrTypeNames[e[4]],
+			UUID:     uuid(i + 1),
+			Bootable: e[0] == 0x80,
+		}, t.size)
+	}
+	if extended == 0 {
+		return table, nil
+	}
+	// Each extended boot record holds a logical partition, relative to
+	// itself, and a link to the next record, relative to the extended
+	// partition.
+	seen := map[uint64]bool{}
+	ebr := extended
+	for n := 5; ebr != 0 && n < 5+maxLogical; n++ {
+		if seen[ebr] {
+			table.Problems = append(table.Problems, "extended boot records form a loop")
+			break
+		}
+		seen[ebr] = true
+		b, err := t.read(ebr*sector, sector)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil || !bytes.Equal(b[510:], bootSignature) {
+			table.Problems = append(table.Problems, fmt.Sprintf("extended boot record at sector %d is damaged", ebr))
+			break
+		}
+		e, next := b[446:462], b[462:478]
+		if count := uint64(binary.LittleEndian.Uint32(e[12:16])); e[4] != 0x00 && count > 0 {
+			table.add(TablePartition{
+				Number:   n,
+				Start:    (ebr + uint64(binary.LittleEndian.Uint32(e[8:12]))) * sector,
+				Size:     count * sector,
+				Type:     fmt.Sprintf("0x%02x", e[4]),
+				TypeName: mbrTypeNames[e[4]],
+				UUID:     uuid(n),
+				Bootable: e[0] == 0x80,
+				Logical:  true,
+			}, t.size)
+		}
+		ebr = 0
+		if isExtended(next[4]) {
+			ebr = extended + uint64(binary.LittleEndian.Uint32(next[8:12]))
+		}
+	}
+	return table, nil
+}
+
+// add records a partition, noting it as a problem instead if it lies
+// outside the device.
+func (t *PartitionTable) add(p TablePartition, size uint64) {
+	if p.Start >= size || p.Size > size-p.Start {
+		t.Problems = append(t.Problems, fmt.Sprintf("partition %d runs past the end of the device", p.Number))
+		return
+	}
+	t.Partitions = append(t.Partitions, p)
+}
+
+// readTargetTable reads the partition table of a disk or image file.
+func readTargetTable(t WipeTarget) (*PartitionTable, error) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, regular, err := regularFileSize(f)
+	if err != nil {
+		return nil, err
+	}
+	if !regular {
+		size = t.Size
+	}
+	return readPartitionTable(f, size)
+}
+
+// tableTargets returns the partitions in the partition table of a disk or
+// image file as targets that cover their extent of it. They are used where
+// the system has no device of its own for them: on image files, on loop
+// devices without partition scanning and on disks whose table the kernel
+// could not read.
+func tableTargets(disk WipeTarget) ([]WipeTarget, *PartitionTable, error) {
+	table, err := readTargetTable(disk)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Extended partitions only hold the logical ones; they are not wiped on
+	// their own.
+	targets := []WipeTarget{}
+	name := deviceName(disk.Path)
+	if last := name[len(name)-1]; last >= '0' && last <= '9' {
+		name += "p"
+	}
+	for _, p := range table.Partitions {
+		if table.Scheme == "dos" && !p.Logical && isExtendedType(p.Type) {
+			continue
+		}
+		targets = append(targets, WipeTarget{
+			Name:       fmt.Sprintf("%s%d", name, p.Number),
+			Path:       disk.Path,
+			Disk:       disk.Disk,
+			ID:         DeviceID{Serial: disk.ID.Serial, WWN: disk.ID.WWN, ByID: disk.ID.ByID, PartUUID: p.UUID},
+			Size:       p.Size,
+			Offset:     p.Start,
+			Rotational: disk.Rotational,
+		})
+	}
+	return targets, table, nil
+}
+
+// verifyTablePartition makes sure the partition table of disk still has the
+// partition part was made from.
+func verifyTablePartition(disk, part WipeTarget) error {
+	parts, _, err := tableTargets(disk)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", part.Path, errDeviceChanged, err)
+	}
+	for _, p := range parts {
+		if p.Offset == part.Offset && p.Size == part.Size && p.ID.PartUUID == part.ID.PartUUID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w: partition %s is no longer in the partition table", part.Path, errDeviceChanged, part.Name)
+}
+
+func isExtendedType(typ string) bool {
+	var b byte
+	if _, err := fmt.Sscanf(typ, "0x%02x", &b); err != nil {
+		return false
+	}
+	return isExtended(b)
+}
+
+// extent tells apart the partitions that share the path of the device they
+// are on.
+func (t WipeTarget) extent() string {
+	if t.Offset == 0 {
+		return t.Path
+	}
+	return fmt.Sprintf("%s@%d", t.Path, t.Offset)
+}
+
+// partitionName is the name a partition is shown with: its device name, or
+// for a partition that only exists in the table, the name the kernel would
+// give it.
+func partitionName(t WipeTarget) string {
+	if t.Offset > 0 {
+		return t.Name
+	}
+	return deviceName(t.Path)
+}
+
+// checkPartitionTable fails if a partition table can still be read from r.
+func checkPartitionTable(r io.ReaderAt, path string, size uint64) error {
+	table, err := readPartitionTable(r, size)
+	if errors.Is(err, errNoPartitionTable) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return fmt.Errorf("%s: %w: %s", path, errTableRemains, table)
+}
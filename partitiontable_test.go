@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	linuxFSType = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+	espType     = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	testDiskID  = "11223344-5566-7788-99AA-BBCCDDEEFF00"
+)
+
+// guidBytes is the inverse of guidString.
+func guidBytes(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range [][2]int{{0, 4}, {4, 6}, {6, 8}} {
+		for i, j := r[0], r[1]-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	return b
+}
+
+type gptPart struct {
+	typ, uuid   string
+	first, last uint64
+	attrs       uint64
+	name        string
+}
+
+// gptImage is a disk of size bytes with a protective MBR and primary and
+// backup GPTs of 128 entries.
+func gptImage(sector, size uint64, parts []gptPart) []byte {
+	img := make([]byte, size)
+	mbrEntry(img, 0, 0x00, 0xEE, 1, uint32(min(size/sector-1, 0xFFFFFFFF)))
+
+	entries := make([]byte, 128*128)
+	for i, p := range parts {
+		e := entries[i*128:]
+		copy(e, guidBytes(p.typ))
+		copy(e[16:], guidBytes(p.uuid))
+		binary.LittleEndian.PutUint64(e[32:], p.first)
+		binary.LittleEndian.PutUint64(e[40:], p.last)
+		binary.LittleEndian.PutUint64(e[48:], p.attrs)
+		putUTF16(e[56:], p.name)
+	}
+	lastLBA := size/sector - 1
+	entrySectors := uint64(len(entries)) / sector
+	backupEntries := lastLBA - entrySectors
+	copy(img[2*sector:], entries)
+	copy(img[backupEntries*sector:], entries)
+
+	header := func(lba, alternate, entriesLBA uint64) {
+		h := img[lba*sector:][:92]
+		copy(h, gptMagic)
+		binary.LittleEndian.PutUint32(h[8:], 0x00010000)
+		binary.LittleEndian.PutUint32(h[12:], 92)
+		binary.LittleEndian.PutUint64(h[24:], lba)
+		binary.LittleEndian.PutUint64(h[32:], alternate)
+		binary.LittleEndian.PutUint64(h[40:], 2+entrySectors)
+		binary.LittleEndian.PutUint64(h[48:], backupEntries-1)
+		copy(h[56:], guidBytes(testDiskID))
+		binary.LittleEndian.PutUint64(h[72:], entriesLBA)
+		binary.LittleEndian.PutUint32(h[80:], 128)
+		binary.LittleEndian.PutUint32(h[84:], 128)
+		binary.LittleEndian.PutUint32(h[88:], crc32.ChecksumIEEE(entries))
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+	}
+	header(1, lastLBA, 2)
+	header(lastLBA, 1, backupEntries)
+	return img
+}
+
+// mbrEntry fills entry i of the partition table in the boot record at b.
+func mbrEntry(b []byte, i int, status, typ byte, start, count uint32) {
+	e := b[446+16*i:]
+	e[0], e[4] = status, typ
+	binary.LittleEndian.PutUint32(e[8:], start)
+	binary.LittleEndian.PutUint32(e[12:], count)
+	copy(b[510:], bootSignature)
+}
+
+func testGPTParts(sector uint64) []gptPart {
+	first := (1 << 20) / sector
+	return []gptPart{
+		{espType, "AAAAAAAA-0000-0000-0000-000000000001", first, 2*first - 1, 0, "EFI"},
+		{linuxFSType, "AAAAAAAA-0000-0000-0000-000000000002", 2 * first, 3*first - 1, 0x4, "root"},
+	}
+}
+
+func wantGPTParts() []TablePartition {
+	return []TablePartition{
+		{Number: 1, Start: 1 << 20, Size: 1 << 20, Type: espType, TypeName: "EFI system", UUID: "aaaaaaaa-0000-0000-0000-000000000001", Name: "EFI"},
+		{Number: 2, Start: 2 << 20, Size: 1 << 20, Type: linuxFSType, TypeName: "Linux filesystem", UUID: "aaaaaaaa-0000-0000-0000-000000000002", Name: "root", Bootable: true},
+	}
+}
+
+func TestReadGPT(t *testing.T) {
+	const size = 4 << 20
+	for _, sector := range []uint64{512, 4096} {
+		damage := func(img []byte, off uint64) []byte {
+			img[off] ^= 0xFF
+			return img
+		}
+		lastLBA := uint64(size)/sector - 1
+		tests := []struct {
+			name     string
+			img      []byte
+			hybrid   bool
+			problems []string
+		}{
+			{"intact", gptImage(sector, size, testGPTParts(sector)), false, nil},
+			{"corrupt primary header", damage(gptImage(sector, size, testGPTParts(sector)), sector+60), false,
+				[]string{"primary GPT: header checksum mismatch; read the backup instead"}},
+			{"corrupt primary entries", damage(gptImage(sector, size, testGPTParts(sector)), 2*sector+40), false,
+				[]string{"primary GPT: entries checksum mismatch; read the backup instead"}},
+			{"corrupt backup header", damage(gptImage(sector, size, testGPTParts(sector)), lastLBA*sector+60), false,
+				[]string{"backup GPT: header checksum mismatch"}},
+			{"no protective MBR", func() []byte {
+				img := gptImage(sector, size, testGPTParts(sector))
+				clear(img[:512])
+				return img
+			}(), false, []string{"protective MBR is missing"}},
+			{"hybrid MBR", func() []byte {
+				img := gptImage(sector, size, testGPTParts(sector))
+				mbrEntry(img, 1, 0x80, 0x0C, uint32((1<<20)/512), (1<<20)/512)
+				return img
+			}(), true, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				table, err := readPartitionTable(bytes.NewReader(tt.img), size)
+				if err != nil {
+					t.Fatalf("%d-byte sectors: %v", sector, err)
+				}
+				if table.Scheme != "gpt" || table.SectorSize != sector || table.DiskID != testDiskID || table.Hybrid != tt.hybrid {
+					t.Errorf("%d-byte sectors: got %+v", sector, table)
+				}
+				if !reflect.DeepEqual(table.Partitions, wantGPTParts()) {
+					t.Errorf("%d-byte sectors: got partitions %+v", sector, table.Partitions)
+				}
+				if !reflect.DeepEqual(table.Problems, tt.problems) {
+					t.Errorf("%d-byte sectors: got problems %q, want %q", sector, table.Problems, tt.problems)
+				}
+			})
+		}
+	}
+}
+
+func TestReadGPTDamaged(t *testing.T) {
+	const size = 4 << 20
+	// Both copies damaged: the protective MBR is all that is left.
+	img := gptImage(512, size, testGPTParts(512))
+	img[512+60] ^= 0xFF
+	img[size-512+60] ^= 0xFF
+	if _, err := readPartitionTable(bytes.NewReader(img), size); !errors.Is(err, errNoPartitionTable) {
+		t.Errorf("both headers corrupt: got %v, want %v", err, errNoPartitionTable)
+	}
+
+	// Partitions that end before they start or past the device are left out.
+	parts := testGPTParts(512)
+	parts[0].last = parts[0].first - 1
+	parts[1].last = size / 512 * 2
+	table, err := readPartitionTable(bytes.NewReader(gptImage(512, size, parts)), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"partition 1 ends before it starts", "partition 2 runs past the end of the device"}
+	if len(table.Partitions) != 0 || !reflect.DeepEqual(table.Problems, want) {
+		t.Errorf("got %+v", table)
+	}
+
+	// An entry count that would make the entries array huge.
+	img = gptImage(512, size, testGPTParts(512))
+	for _, lba := range []uint64{1, size/512 - 1} {
+		h := img[lba*512:][:92]
+		binary.LittleEndian.PutUint32(h[80:], 0xFFFFFFFF)
+		clear(h[16:20])
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+	}
+	if _, err := readPartitionTable(bytes.NewReader(img), size); !errors.Is(err, errNoPartitionTable) {
+		t.Errorf("huge entry count: got %v, want %v", err, errNoPartitionTable)
+	}
+}
+
+// mbrImage has a primary partition, an extended partition at sector 4096
+// and logical partitions in EBRs at sectors 4096 and 6144.
+func mbrImage() []byte {
+	img := make([]byte, 8<<20)
+	binary.LittleEndian.PutUint32(img[440:], 0xDEADBEEF)
+	mbrEntry(img, 0, 0x80, 0x83, 2048, 2048)
+	mbrEntry(img, 1, 0x00, 0x05, 4096, 8192)
+	ebr := img[4096*512:]
+	mbrEntry(ebr, 0, 0x00, 0x82, 2048, 1024)
+	mbrEntry(ebr, 1, 0x00, 0x05, 2048, 4096)
+	ebr = img[6144*512:]
+	mbrEntry(ebr, 0, 0x00, 0x8E, 2048, 2048)
+	return img
+}
+
+func TestReadMBR(t *testing.T) {
+	img := mbrImage()
+	table, err := readPartitionTable(bytes.NewReader(img), uint64(len(img)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PartitionTable{
+		Scheme:     "dos",
+		SectorSize: 512,
+		DiskID:     "0xdeadbeef",
+		Partitions: []TablePartition{
+			{Number: 1, Start: 2048 * 512, Size: 2048 * 512, Type: "0x83", TypeName: "Linux", UUID: "deadbeef-01", Bootable: true},
+			{Number: 2, Start: 4096 * 512, Size: 8192 * 512, Type: "0x05", TypeName: "Extended", UUID: "deadbeef-02"},
+			{Number: 5, Start: 6144 * 512, Size: 1024 * 512, Type: "0x82", TypeName: "Linux swap", UUID: "deadbeef-05", Logical: true},
+			{Number: 6, Start: 8192 * 512, Size: 2048 * 512, Type: "0x8e", TypeName: "Linux LVM", UUID: "deadbeef-06", Logical: true},
+		},
+	}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("got %+v\nwant %+v", table, want)
+	}
+
+	// The extended partition itself is not a target.
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	targets, _, err := tableTargets(WipeTarget{Path: path, Size: uint64(len(img))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	if want := []string{"disk.img1", "disk.img5", "disk.img6"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("targets %q, want %q", names, want)
+	}
+}
+
+func TestReadMBRDamagedChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		damage   func(img []byte)
+		logical  int
+		problems []string
+	}{
+		{"loop", func(img []byte) {
+			// The second EBR links back to the first.
+			mbrEntry(img[6144*512:], 1, 0x00, 0x05, 0, 4096)
+		}, 2, []string{"extended boot records form a loop"}},
+		{"self loop", func(img []byte) {
+			mbrEntry(img[4096*512:], 1, 0x00, 0x05, 0, 4096)
+		}, 1, []string{"extended boot records form a loop"}},
+		{"missing signature", func(img []byte) {
+			clear(img[6144*512+510:][:2])
+		}, 1, []string{"extended boot record at sector 6144 is damaged"}},
+		{"link past the device", func(img []byte) {
+			mbrEntry(img[6144*512:], 1, 0x00, 0x05, 0x7FFFFFFF, 4096)
+		}, 2, []string{"extended boot record at sector 2147487743 is damaged"}},
+		{"two extended partitions", func(img []byte) {
+			mbrEntry(img, 2, 0x00, 0x0F, 12288, 2048)
+		}, 2, []string{"more than one extended partition"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := mbrImage()
+			tt.damage(img)
+			table, err := readPartitionTable(bytes.NewReader(img), uint64(len(img)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			logical := 0
+			for _, p := range table.Partitions {
+				if p.Logical {
+					logical++
+				}
+			}
+			if logical != tt.logical {
+				t.Errorf("got %d logical partitions, want %d", logical, tt.logical)
+			}
+			if !reflect.DeepEqual(table.Problems, tt.problems) {
+				t.Errorf("got problems %q, want %q", table.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+// A chain of distinct EBRs longer than maxLogical stops at the bound.
+func TestReadMBRLongChain(t *testing.T) {
+	img := make([]byte, (4096+2*(maxLogical+10))*512)
+	mbrEntry(img, 0, 0x00, 0x05, 4096, uint32(2*(maxLogical+10)))
+	for i := 0; i < maxLogical+10; i++ {
+		ebr := img[(4096+2*i)*512:]
+		mbrEntry(ebr, 0, 0x00, 0x83, 1, 1)
+		mbrEntry(ebr, 1, 0x00, 0x05, uint32(2*(i+1)), 2)
+	}
+	table, err := readPartitionTable(bytes.NewReader(img), uint64(len(img)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(table.Partitions) - 1; got != maxLogical {
+		t.Errorf("got %d logical partitions, want %d", got, maxLogical)
+	}
+}
+
+func TestReadPartitionTableNone(t *testing.T) {
+	for name, img := range map[string][]byte{
+		"blank":      make([]byte, 1<<20),
+		"short":      make([]byte, 100),
+		"fat":        fatImage("FAT16", 20000),
+		"ntfs":       ntfsImage(),
+		"signature":  append(make([]byte, 510), bootSignature...),
+		"bad status": func() []byte { b := make([]byte, 512); mbrEntry(b, 0, 0x12, 0x83, 1, 1); return b }(),
+		"protective": func() []byte { b := make([]byte, 1<<20); mbrEntry(b, 0, 0x00, 0xEE, 1, 2047); return b }(),
+	} {
+		if table, err := readPartitionTable(bytes.NewReader(img), uint64(len(img))); !errors.Is(err, errNoPartitionTable) {
+			t.Errorf("%s: got %v, %v; want %v", name, table, err, errNoPartitionTable)
+		}
+	}
+}
+
+func TestCheckPartitionTable(t *testing.T) {
+	img := gptImage(512, 4<<20, testGPTParts(512))
+	if err := checkPartitionTable(bytes.NewReader(img), "disk.img", uint64(len(img))); !errors.Is(err, errTableRemains) {
+		t.Errorf("got %v, want %v", err, errTableRemains)
+	}
+	if err := checkPartitionTable(bytes.NewReader(make([]byte, 1<<20)), "disk.img", 1<<20); err != nil {
+		t.Errorf("blank device: %v", err)
+	}
+}
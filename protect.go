@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errProtected = errors.New("device hosts the running system")
+
+// checkProtected refuses targets that host the running system, unless the
+// job explicitly allows it.
+func checkProtected(t WipeTarget) error {
+	protected, err := protectedDevices()
+	if err != nil {
+		// Without knowing where the system lives, nothing is safe to wipe.
+		return fmt.Errorf("finding system devices: %w", err)
+	}
+	for _, target := range t.devices() {
+		if reason, ok := protected[target.Path]; ok {
+			return fmt.Errorf("%s: %w (%s)", target.Path, errProtected, reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Mount points whose disks the running system cannot live without.
+var systemMountPoints = []string{"/", "/boot", "/boot/efi", "/efi", "/usr", "/var"}
+
+// protectedDevices maps every device path that hosts the running system to
+// the reason it is protected: its root, boot and EFI mounts, active swap
+// and the Wipr executable. Partitions are traced back through
+// device-mapper, md and loop devices to the disks that hold them.
+func protectedDevices() (map[string]string, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	protected := map[string]string{}
+	var mark func(dev, reason string)
+	mark = func(dev, reason string) {
+		name, ok := resolveBlockDev(dev, mounts)
+		if !ok {
+			return
+		}
+		for _, n := range blockStack(name) {
+			path := "/dev/" + n
+			if _, seen := protected[path]; seen {
+				continue
+			}
+			protected[path] = reason
+			if backing, err := os.ReadFile(filepath.Join("/sys/class/block", n, "loop", "backing_file")); err == nil {
+				if dev, err := fileDeviceNumber(strings.TrimSpace(string(backing))); err == nil {
+					mark(dev, reason)
+				}
+			}
+		}
+	}
+
+	for _, m := range mounts {
+		for _, mp := range systemMountPoints {
+			if m.MountPoint == mp {
+				mark(m.Dev, mp+" is mounted from it")
+			}
+		}
+	}
+
+	swaps, err := activeSwaps()
+	if err != nil {
+		return nil, err
+	}
+	for _, swap := range swaps {
+		dev, err := deviceNumber(swap)
+		if err != nil {
+			dev, err = fileDeviceNumber(swap)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("swap %s: %w", swap, err)
+		}
+		mark(dev, "active swap "+swap)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dev, err := fileDeviceNumber(exe)
+	if err != nil {
+		return nil, err
+	}
+	mark(dev, "holds the running "+filepath.Base(exe))
+	return protected, nil
+}
+
+// deviceNumber returns the "major:minor" of a block device node.
+func deviceNumber(path string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", fmt.Errorf("%s is not a block device", path)
+	}
+	return fmt.Sprintf("%d:%d", unix.Major(st.Rdev), unix.Minor(st.Rdev)), nil
+}
+
+// fileDeviceNumber returns the "major:minor" of the filesystem holding path.
+func fileDeviceNumber(path string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)), nil
+}
@@ -0,0 +1,77 @@
+//go:build windows
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+const ioctlVolumeGetVolumeDiskExtents = 0x00560000
+
+// protectedDevices maps the Windows volume, the volume holding the Wipr
+// executable and the physical drives under them to the reason they are
+// protected.
+func protectedDevices() (map[string]string, error) {
+	sysDir, err := windows.GetSystemDirectory()
+	if err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	protected := map[string]string{}
+	volumes := []struct{ volume, reason string }{
+		{filepath.VolumeName(sysDir), "holds Windows"},
+		{filepath.VolumeName(exe), "holds the running " + filepath.Base(exe)},
+	}
+	for _, v := range volumes {
+		if v.volume == "" {
+			continue
+		}
+		path := `\\.\` + strings.ToUpper(v.volume)
+		if _, seen := protected[path]; !seen {
+			protected[path] = v.reason
+		}
+		disks, err := volumeDisks(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, n := range disks {
+			disk := fmt.Sprintf(`\\.\PHYSICALDRIVE%d`, n)
+			if _, seen := protected[disk]; !seen {
+				protected[disk] = v.reason
+			}
+		}
+	}
+	return protected, nil
+}
+
+// volumeDisks returns the numbers of the physical drives a volume spans.
+func volumeDisks(path string) ([]uint32, error) {
+	handle, err := windows.CreateFile(windows.StringToUTF16Ptr(path), 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(handle)
+	// VOLUME_DISK_EXTENTS: a count padded to 8 bytes, then 24-byte extents
+	// that start with the disk number.
+	buf := make([]byte, 8+24*32)
+	var returned uint32
+	if err := windows.DeviceIoControl(handle, ioctlVolumeGetVolumeDiskExtents, nil, 0, &buf[0], uint32(len(buf)), &returned, nil); err != nil {
+		return nil, err
+	}
+	count := min(binary.LittleEndian.Uint32(buf), 32)
+	disks := []uint32{}
+	for i := uint32(0); i < count; i++ {
+		disks = append(disks, binary.LittleEndian.Uint32(buf[8+24*i:]))
+	}
+	return disks, nil
+}
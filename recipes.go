@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const recipeFileName = "recipes.toml"
+
+// recipeFile is the layout of recipes.toml, e.g.
+//
+//	[[recipe]]
+//	id = "alternating"
+//	name = "Alternating 0x55/0xAA"
+//	standard = "In-house"
+//
+//	[[recipe.pass]]
+//	pattern = "0x55"
+//
+//	[[recipe.pass]]
+//	pattern = "0xAA"
+//
+//	[[recipe.pass]]
+//	random = true
+//	seed = "job-42"
+//	verify = true
+type recipeFile struct {
+	Recipe []recipe `toml:"recipe"`
+}
+
+type recipe struct {
+	ID       string       `toml:"id"`
+	Name     string       `toml:"name"`
+	Standard string       `toml:"standard"`
+	Verify   bool         `toml:"verify"`
+	Pass     []recipePass `toml:"pass"`
+}
+
+type recipePass struct {
+	Pattern    string `toml:"pattern"`
+	Complement bool   `toml:"complement"`
+	Random     bool   `toml:"random"`
+	Seed       string `toml:"seed"`
+	Verify     bool   `toml:"verify"`
+}
+
+func recipePaths() []string {
+	paths := []string{}
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), recipeFileName))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "wipr", recipeFileName))
+	}
+	return paths
+}
+
+// loadRecipes registers the recipes found next to the executable and in the
+// user config directory. Missing files are skipped; a malformed file is
+// rejected as a whole so a half-loaded recipe set is never offered.
+func loadRecipes() error {
+	var errs []error
+	for _, path := range recipePaths() {
+		methods, err := readRecipes(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		wipeMethods = append(wipeMethods, methods...)
+	}
+	return errors.Join(errs...)
+}
+
+func readRecipes(path string) ([]*WipeMethod, error) {
+	var file recipeFile
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+	}
+	methods := []*WipeMethod{}
+	for i, r := range file.Recipe {
+		m, err := r.method()
+		if err != nil {
+			return nil, fmt.Errorf("%s: recipe %d: %w", path, i+1, err)
+		}
+		for _, existing := range append(wipeMethods, methods...) {
+			if existing.ID == m.ID || existing.Name == m.Name {
+				return nil, fmt.Errorf("%s: recipe %d: %q clashes with the existing method %q", path, i+1, m.Name, existing.Name)
+			}
+		}
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
+
+func (r recipe) method() (*WipeMethod, error) {
+	if strings.TrimSpace(r.ID) == "" {
+		return nil, errors.New("missing id")
+	}
+	if strings.TrimSpace(r.Name) == "" {
+		return nil, fmt.Errorf("%q: missing name", r.ID)
+	}
+	if len(r.Pass) == 0 {
+		return nil, fmt.Errorf("%q: no passes defined", r.ID)
+	}
+	passes := []Pass{}
+	for i, rp := range r.Pass {
+		p, err := rp.pass()
+		if err != nil {
+			return nil, fmt.Errorf("%q: pass %d: %w", r.ID, i+1, err)
+		}
+		passes = append(passes, p)
+	}
+	if _, err := resolvePasses(passes); err != nil {
+		return nil, fmt.Errorf("%q: %w", r.ID, err)
+	}
+	standard := r.Standard
+	if standard == "" {
+		standard = "Custom recipe"
+	}
+	return &WipeMethod{
+		ID:       r.ID,
+		Name:     r.Name,
+		Standard: standard,
+		Passes:   passes,
+		Verify:   r.Verify,
+	}, nil
+}
+
+func (rp recipePass) pass() (Pass, error) {
+	kinds := 0
+	for _, set := range []bool{rp.Pattern != "", rp.Complement, rp.Random} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return Pass{}, errors.New("exactly one of pattern, complement or random must be set")
+	}
+	if rp.Seed != "" && !rp.Random {
+		return Pass{}, errors.New("seed is only valid on a random pass")
+	}
+	switch {
+	case rp.Complement:
+		return Pass{Kind: PassComplement, Verify: rp.Verify}, nil
+	case rp.Random:
+		return Pass{Kind: PassRandom, Seed: rp.Seed, Verify: rp.Verify}, nil
+	}
+	pattern, err := parsePattern(rp.Pattern)
+	if err != nil {
+		return Pass{}, err
+	}
+	return Pass{Kind: PassPattern, Pattern: pattern, Verify: rp.Verify}, nil
+}
+
+// parsePattern accepts a hex byte string such as "0x55" or "0xDEADBEEF".
+func parsePattern(s string) ([]byte, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(digits) == 0 || len(digits)%2 != 0 {
+		return nil, fmt.Errorf("pattern %q must be an even number of hex digits, e.g. \"0x55\"", s)
+	}
+	pattern, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q is not valid hex", s)
+	}
+	if len(pattern) > 512 {
+		return nil, fmt.Errorf("pattern %q is longer than 512 bytes", s)
+	}
+	return pattern, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRecipes(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, recipeFileName)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadRecipes(t *testing.T) {
+	path := writeRecipes(t, t.TempDir(), `
+[[recipe]]
+id = "alternating"
+name = "Alternating"
+verify = true
+
+[[recipe.pass]]
+pattern = "0x55"
+
+[[recipe.pass]]
+complement = true
+verify = true
+
+[[recipe.pass]]
+pattern = "0XdeadBEEF"
+
+[[recipe.pass]]
+random = true
+seed = "job-42"
+verify = true
+
+[[recipe]]
+id = "in-house"
+name = "In-house"
+standard = "Policy 7"
+
+[[recipe.pass]]
+random = true
+`)
+	methods, err := readRecipes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 2 {
+		t.Fatalf("got %d methods, want 2", len(methods))
+	}
+	m := methods[0]
+	if m.ID != "alternating" || m.Name != "Alternating" || m.Standard != "Custom recipe" || !m.Verify {
+		t.Errorf("got %+v", m)
+	}
+	want := []Pass{
+		patternPass(0x55),
+		{Kind: PassComplement, Verify: true},
+		patternPass(0xDE, 0xAD, 0xBE, 0xEF),
+		{Kind: PassRandom, Seed: "job-42", Verify: true},
+	}
+	if len(m.Passes) != len(want) {
+		t.Fatalf("got passes %v, want %v", m.Passes, want)
+	}
+	for i, p := range m.Passes {
+		w := want[i]
+		if p.Kind != w.Kind || !bytes.Equal(p.Pattern, w.Pattern) || p.Seed != w.Seed || p.Verify != w.Verify {
+			t.Errorf("pass %d: got %+v, want %+v", i+1, p, w)
+		}
+	}
+	if m := methods[1]; m.Standard != "Policy 7" || m.Verify || len(m.Passes) != 1 || m.Passes[0].Verify {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestReadRecipesInvalid(t *testing.T) {
+	long := "0x" + strings.Repeat("55", 513)
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"bad hex", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "0x5G"`, `recipe 1: "r": pass 1: pattern "0x5G" is not valid hex`},
+		{"odd digits", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "0x555"`, `recipe 1: "r": pass 1: pattern "0x555" must be an even number of hex digits, e.g. "0x55"`},
+		{"empty pattern", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "0x"`, `recipe 1: "r": pass 1: pattern "0x" must be an even number of hex digits, e.g. "0x55"`},
+		{"oversized pattern", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "` + long + `"`, `recipe 1: "r": pass 1: pattern "` + long + `" is longer than 512 bytes`},
+		{"unknown pass kind", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+zero = true`, `unknown key "recipe.pass.zero"`},
+		{"no pass kind", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+verify = true`, `recipe 1: "r": pass 1: exactly one of pattern, complement or random must be set`},
+		{"two pass kinds", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "0x00"
+random = true`, `recipe 1: "r": pass 1: exactly one of pattern, complement or random must be set`},
+		{"seed on a pattern", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+pattern = "0x00"
+seed = "s"`, `recipe 1: "r": pass 1: seed is only valid on a random pass`},
+		{"verify not a bool", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+random = true
+verify = "yes"`, `toml: line 6 (last key "recipe.pass.verify"): incompatible types: TOML value has type string; destination has type boolean`},
+		{"missing id", `[[recipe]]
+name = "R"
+[[recipe.pass]]
+random = true`, `recipe 1: missing id`},
+		{"missing name", `[[recipe]]
+id = "r"
+[[recipe.pass]]
+random = true`, `recipe 1: "r": missing name`},
+		{"no passes", `[[recipe]]
+id = "r"
+name = "R"`, `recipe 1: "r": no passes defined`},
+		{"complement first", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+complement = true`, `recipe 1: "r": pass 1: complement needs a previous pass`},
+		{"complement of random", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+random = true
+[[recipe.pass]]
+complement = true`, `recipe 1: "r": pass 2: cannot complement a random pass`},
+		{"duplicate id", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+random = true
+[[recipe]]
+id = "r"
+name = "Other"
+[[recipe.pass]]
+random = true`, `recipe 2: "Other" clashes with the existing method "R"`},
+		{"duplicate name", `[[recipe]]
+id = "r"
+name = "R"
+[[recipe.pass]]
+random = true
+[[recipe]]
+id = "s"
+name = "R"
+[[recipe.pass]]
+random = true`, `recipe 2: "R" clashes with the existing method "R"`},
+		{"built-in id", `[[recipe]]
+id = "dod-3"
+name = "Mine"
+[[recipe.pass]]
+random = true`, `recipe 1: "Mine" clashes with the existing method "DoD 5220.22-M (3 passes)"`},
+		{"built-in name", `[[recipe]]
+id = "mine"
+name = "Gutmann (35 passes)"
+[[recipe.pass]]
+random = true`, `recipe 1: "Gutmann (35 passes)" clashes with the existing method "Gutmann (35 passes)"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeRecipes(t, t.TempDir(), tt.content)
+			methods, err := readRecipes(path)
+			if err == nil {
+				t.Fatalf("accepted: %+v", methods)
+			}
+			if want := path + ": " + tt.err; err.Error() != want {
+				t.Errorf("got error\n\t%v\nwant\n\t%s", err, want)
+			}
+		})
+	}
+}
+
+// A malformed file is rejected as a whole, and the good files still load.
+func TestLoadRecipes(t *testing.T) {
+	builtIn := wipeMethods
+	t.Cleanup(func() { wipeMethods = builtIn })
+	wipeMethods = append([]*WipeMethod{}, builtIn...)
+
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("APPDATA", config)
+	dir := filepath.Join(config, "wipr")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadRecipes(); err != nil {
+		t.Fatalf("no recipe files: %v", err)
+	}
+
+	writeRecipes(t, dir, `[[recipe]]
+id = "good"
+name = "Good"
+[[recipe.pass]]
+random = true
+[[recipe]]
+id = "bad"
+name = "Bad"`)
+	if err := loadRecipes(); err == nil {
+		t.Error("malformed file loaded")
+	}
+	if MethodByID("good") != nil {
+		t.Error("recipe from a malformed file registered")
+	}
+
+	writeRecipes(t, dir, `[[recipe]]
+id = "good"
+name = "Good"
+[[recipe.pass]]
+random = true`)
+	if err := loadRecipes(); err != nil {
+		t.Fatal(err)
+	}
+	if MethodByID("good") == nil || len(wipeMethods) != len(builtIn)+1 {
+		t.Error("recipe not registered")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import "sync"
+
+// jobControl pauses and cancels one job independently of the others that
+// run next to it.
+type jobControl struct {
+	pause  chan bool
+	cancel chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	// pauses counts the open Cancel dialogs holding the job, so that it
+	// only resumes once every one of them is answered.
+	pauses int
+}
+
+func newJobControl() *jobControl {
+	return &jobControl{
+		pause:  make(chan bool, 1),
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *jobControl) ctl() wipeControl {
+	return wipeControl{cancel: c.cancel, pause: c.pause}
+}
+
+// Pause holds the job at its next checkpoint until Resume or Cancel.
+func (c *jobControl) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pauses++
+	if c.pauses > 1 {
+		return
+	}
+	select {
+	case c.pause <- true:
+	default:
+	}
+}
+
+// Resume lets a paused job carry on. It never blocks: a pause the job has
+// not reached a checkpoint for yet, because it is queued behind another job
+// or in the middle of a long write, is taken back instead.
+func (c *jobControl) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pauses == 0 {
+		return
+	}
+	c.pauses--
+	if c.pauses > 0 {
+		return
+	}
+	select {
+	case <-c.pause:
+		return
+	default:
+	}
+	select {
+	case c.pause <- false:
+	default:
+	}
+}
+
+func (c *jobControl) Cancel() {
+	c.once.Do(func() { close(c.cancel) })
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (c *jobControl) Done() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wipeJob runs one job for runJobs; tests stand in for WipeDevice here.
+var wipeJob = WipeDevice
+
+// runJobs wipes every job at once, except that jobs on the same disk run
+// one after another rather than competing for it. A failed or cancelled job
+// does not stop the others. finished is called as each job ends, from the
+// goroutine that ran it.
+func runJobs(jobs []WipeJob, ctls []*jobControl, progress func(job int, p WipeProgress), finished func(job int, r WipeResult)) []WipeResult {
+	results := make([]WipeResult, len(jobs))
+	queues := map[string][]int{}
+	order := []string{}
+	for i, job := range jobs {
+		disk := job.Target.Disk
+		if disk == "" {
+			disk = job.Target.Path
+		}
+		if _, ok := queues[disk]; !ok {
+			order = append(order, disk)
+		}
+		queues[disk] = append(queues[disk], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, disk := range order {
+		wg.Add(1)
+		go func(queue []int) {
+			defer wg.Done()
+			for _, i := range queue {
+				ctl := ctls[i].ctl()
+				// A job cancelled while it waited for its disk never starts.
+				if err := ctl.checkpoint(); err != nil {
+					results[i] = WipeResult{Target: jobs[i].Target, Method: jobs[i].Method, Err: err}
+				} else {
+					results[i] = wipeJob(jobs[i], ctl, func(p WipeProgress) {
+						if progress != nil {
+							progress(i, p)
+						}
+					})
+				}
+				close(ctls[i].done)
+				if finished != nil {
+					finished(i, results[i])
+				}
+			}
+		}(queues[disk])
+	}
+	wg.Wait()
+	return results
+}
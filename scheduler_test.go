@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWipe stands in for WipeDevice for the duration of a test.
+func fakeWipe(t *testing.T, wipe func(job WipeJob, ctl wipeControl, progress func(WipeProgress)) WipeResult) {
+	t.Helper()
+	t.Cleanup(func() { wipeJob = WipeDevice })
+	wipeJob = wipe
+}
+
+func testJobs(disks ...string) ([]WipeJob, []*jobControl) {
+	jobs := make([]WipeJob, len(disks))
+	ctls := make([]*jobControl, len(disks))
+	for i, disk := range disks {
+		jobs[i] = WipeJob{Target: WipeTarget{Path: disk + string(rune('1'+i)), Disk: disk}, Method: defaultMethod}
+		ctls[i] = newJobControl()
+	}
+	return jobs, ctls
+}
+
+// waitFor fails the test if ch is not ready within a few seconds.
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestRunJobsSameDiskInOrder(t *testing.T) {
+	var mu sync.Mutex
+	running := map[string]int{}
+	started := []string{}
+	fakeWipe(t, func(job WipeJob, ctl wipeControl, progress func(WipeProgress)) WipeResult {
+		mu.Lock()
+		running[job.Target.Disk]++
+		if n := running[job.Target.Disk]; n > 1 {
+			t.Errorf("%d jobs on %s at once", n, job.Target.Disk)
+		}
+		started = append(started, job.Target.Path)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		progress(WipeProgress{Done: 1, Total: 1})
+		mu.Lock()
+		running[job.Target.Disk]--
+		mu.Unlock()
+		return WipeResult{Target: job.Target, Method: job.Method, Passes: 1}
+	})
+
+	jobs, ctls := testJobs("/dev/sda", "/dev/sdb", "/dev/sda", "/dev/sda", "/dev/sdb")
+	progressed := make([]bool, len(jobs))
+	finished := []int{}
+	results := runJobs(jobs, ctls, func(i int, p WipeProgress) {
+		mu.Lock()
+		progressed[i] = true
+		mu.Unlock()
+	}, func(i int, r WipeResult) {
+		mu.Lock()
+		finished = append(finished, i)
+		mu.Unlock()
+		if !ctls[i].Done() {
+			t.Errorf("job %d not done when finished", i)
+		}
+	})
+
+	for i, r := range results {
+		if r.Err != nil || r.Target.Path != jobs[i].Target.Path || !progressed[i] {
+			t.Errorf("job %d: %+v, progress reported %v", i, r, progressed[i])
+		}
+	}
+	if len(finished) != len(jobs) {
+		t.Errorf("finished called for %v", finished)
+	}
+	// Jobs on one disk start in the order they were given.
+	order := map[string][]string{}
+	for _, path := range started {
+		disk := path[:len(path)-1]
+		order[disk] = append(order[disk], path)
+	}
+	if got := order["/dev/sda"]; len(got) != 3 || got[0] != "/dev/sda1" || got[1] != "/dev/sda3" || got[2] != "/dev/sda4" {
+		t.Errorf("/dev/sda jobs started in order %v", got)
+	}
+}
+
+func TestRunJobsDisksConcurrently(t *testing.T) {
+	// Each job waits for the other to start, which only works if they run
+	// at the same time.
+	started := map[string]chan struct{}{"/dev/sda": make(chan struct{}), "/dev/sdb": make(chan struct{})}
+	fakeWipe(t, func(job WipeJob, ctl wipeControl, progress func(WipeProgress)) WipeResult {
+		close(started[job.Target.Disk])
+		other := started["/dev/sda"]
+		if job.Target.Disk == "/dev/sda" {
+			other = started["/dev/sdb"]
+		}
+		select {
+		case <-other:
+		case <-time.After(5 * time.Second):
+			return WipeResult{Target: job.Target, Err: errors.New("the other disk's job never started")}
+		}
+		return WipeResult{Target: job.Target}
+	})
+	jobs, ctls := testJobs("/dev/sda", "/dev/sdb")
+	for i, r := range runJobs(jobs, ctls, nil, nil) {
+		if r.Err != nil {
+			t.Errorf("job %d: %v", i, r.Err)
+		}
+	}
+}
+
+// A job paused at a checkpoint can still be cancelled, and a job cancelled
+// while it waits for its disk never starts.
+func TestRunJobsCancelWhilePaused(t *testing.T) {
+	running := make(chan struct{})
+	calls := 0
+	fakeWipe(t, func(job WipeJob, ctl wipeControl, progress func(WipeProgress)) WipeResult {
+		calls++
+		close(running)
+		for {
+			if err := ctl.checkpoint(); err != nil {
+				return WipeResult{Target: job.Target, Err: err}
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	jobs, ctls := testJobs("/dev/sda", "/dev/sda")
+	done := make(chan []WipeResult)
+	go func() { done <- runJobs(jobs, ctls, nil, nil) }()
+
+	waitFor(t, running, "the first job to start")
+	ctls[0].Pause()
+	// The pause is taken at the next checkpoint.
+	for len(ctls[0].pause) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctls[1].Cancel()
+	ctls[0].Cancel()
+	var results []WipeResult
+	select {
+	case results = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled jobs did not return")
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, errCancelled) {
+			t.Errorf("job %d: got %v, want %v", i, r.Err, errCancelled)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("wipe started %d times, want once", calls)
+	}
+}
+
+func TestJobControlPauseResume(t *testing.T) {
+	job := newJobControl()
+	ctl := job.ctl()
+
+	// Resuming a pause the job never took does not block, and leaves
+	// nothing behind for the next checkpoint.
+	job.Pause()
+	job.Resume()
+	job.Resume()
+	if err := ctl.checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two pauses need two resumes.
+	job.Pause()
+	job.Pause()
+	passed := make(chan struct{})
+	go func() {
+		if err := ctl.checkpoint(); err != nil {
+			t.Error(err)
+		}
+		close(passed)
+	}()
+	for len(job.pause) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	job.Resume()
+	select {
+	case <-passed:
+		t.Fatal("resumed while a second pause was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	job.Resume()
+	waitFor(t, passed, "the checkpoint to resume")
+
+	job.Cancel()
+	job.Cancel()
+	if err := ctl.checkpoint(); !errors.Is(err, errCancelled) {
+		t.Errorf("got %v, want %v", err, errCancelled)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Number of times a shredded file is renamed before it is unlinked, so
+// that its original name does not survive in the directory entries.
+const shredRenames = 3
+
+type ShredFailure struct {
+	Path string
+	Err  error
+}
+
+type ShredResult struct {
+	Method       *WipeMethod
+	Files        int
+	BytesWritten uint64
+	// Skipped holds symlinks and special files, which are never followed or
+	// overwritten.
+	Skipped  []string
+	Failures []ShredFailure
+	Err      error
+}
+
+type ShredProgress struct {
+	File       string
+	Files      int
+	FilesTotal int
+	Done       uint64
+	Total      uint64
+}
+
+type shredFile struct {
+	path string
+	info os.FileInfo
+}
+
+// ShredPaths overwrites every regular file under paths in place with
+// method, then renames, truncates and unlinks it. Directories are removed
+// once emptied. A failure on one file is recorded and the rest continue.
+func ShredPaths(paths []string, method *WipeMethod, verifyPct int, ctl wipeControl, progress func(ShredProgress)) (result ShredResult) {
+	result.Method = method
+	if err := overwriteOnly(method); err != nil {
+		result.Err = err
+		return
+	}
+	passes, verified, err := preparePasses(method)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	percent := verifyPercent(verifyPct)
+
+	files, dirs := collectShredFiles(paths, &result)
+	var total uint64
+	for _, f := range files {
+		size := uint64(f.info.Size())
+		for i := range passes {
+			total += size
+			if verified[i] {
+				total += size * uint64(percent) / 100
+			}
+		}
+	}
+
+	var done uint64
+	for i, file := range files {
+		start := done
+		n, err := shredOne(file, passes, verified, percent, ctl, func(fileDone uint64) {
+			done = start + fileDone
+			if progress != nil {
+				progress(ShredProgress{File: file.path, Files: i + 1, FilesTotal: len(files), Done: done, Total: total})
+			}
+		})
+		result.BytesWritten += n
+		if errors.Is(err, errCancelled) {
+			result.Err = err
+			return
+		}
+		if err != nil {
+			result.Failures = append(result.Failures, ShredFailure{Path: file.path, Err: err})
+			continue
+		}
+		result.Files++
+	}
+
+	// Deepest directories come last in walk order, so remove them first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) > 0 {
+			result.Failures = append(result.Failures, ShredFailure{Path: dirs[i], Err: errors.New("directory is not empty, left in place")})
+			continue
+		}
+		if err := scrubAndRemove(dirs[i]); err != nil {
+			result.Failures = append(result.Failures, ShredFailure{Path: dirs[i], Err: err})
+		}
+	}
+	return
+}
+
+// shredItem is one of the files or folders selected for shredding, with
+// the regular files under it that will be overwritten.
+type shredItem struct {
+	Path  string
+	Dir   bool
+	Files int
+	Size  uint64
+	// Skipped counts the symlinks, special files and unreadable entries
+	// under it that will be left alone.
+	Skipped int
+}
+
+// shredSummary describes what shredding paths will destroy, for the
+// confirmation dialog.
+func shredSummary(paths []string) []shredItem {
+	items := []shredItem{}
+	for _, path := range paths {
+		var result ShredResult
+		files, _ := collectShredFiles([]string{path}, &result)
+		item := shredItem{Path: path, Files: len(files), Skipped: len(result.Skipped) + len(result.Failures)}
+		if info, err := os.Lstat(path); err == nil {
+			item.Dir = info.IsDir()
+		}
+		for _, f := range files {
+			item.Size += uint64(f.info.Size())
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func collectShredFiles(paths []string, result *ShredResult) (files []shredFile, dirs []string) {
+	seen := make(map[string]bool)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				result.Failures = append(result.Failures, ShredFailure{Path: path, Err: err})
+				return nil
+			}
+			if seen[path] {
+				return nil
+			}
+			seen[path] = true
+			switch {
+			case d.Type()&fs.ModeSymlink != 0:
+				result.Skipped = append(result.Skipped, path)
+			case d.IsDir():
+				dirs = append(dirs, path)
+			case d.Type().IsRegular():
+				info, err := d.Info()
+				if err != nil {
+					result.Failures = append(result.Failures, ShredFailure{Path: path, Err: err})
+					return nil
+				}
+				files = append(files, shredFile{path: path, info: info})
+			default:
+				result.Skipped = append(result.Skipped, path)
+			}
+			return nil
+		})
+		if err != nil {
+			result.Failures = append(result.Failures, ShredFailure{Path: root, Err: err})
+		}
+	}
+	return
+}
+
+func shredOne(file shredFile, passes []Pass, verified []bool, percent int, ctl wipeControl, progress func(uint64)) (uint64, error) {
+	f, err := os.OpenFile(file.path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	// Refuse if the path was swapped (e.g. for a symlink) after it was listed.
+	if !os.SameFile(info, file.info) {
+		f.Close()
+		return 0, errors.New("file changed since it was selected")
+	}
+
+	size := uint64(info.Size())
+	buf := make([]byte, min(uint64(wipeChunkSize), max(size, 1)))
+	var done, writtenTotal uint64
+	for i, pass := range passes {
+		if size == 0 {
+			break
+		}
+		start := done
+		written, err := writePass(&deviceFile{File: f, size: size}, size, pass, buf, ctl, func(n uint64) { progress(start + n) })
+		done += written
+		writtenTotal += written
+		if err != nil {
+			f.Close()
+			return writtenTotal, fmt.Errorf("pass %d (%s): %w", i+1, pass, err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return writtenTotal, fmt.Errorf("pass %d (%s): sync: %w", i+1, pass, err)
+		}
+		if !verified[i] {
+			continue
+		}
+		if err := dropCache(f); err != nil {
+			f.Close()
+			return writtenTotal, fmt.Errorf("pass %d (%s): drop cache: %w", i+1, pass, err)
+		}
+		start = done
+		vr, err := verifyPass(f, size, pass, percent, ctl, func(n uint64) { progress(start + n) })
+		done += vr.BytesChecked
+		if err == nil && len(vr.Mismatches) > 0 {
+			err = fmt.Errorf("%w: %s", errVerifyFailed, vr)
+		}
+		if err != nil {
+			f.Close()
+			return writtenTotal, fmt.Errorf("pass %d (%s): verify: %w", i+1, pass, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return writtenTotal, err
+	}
+	return writtenTotal, scrubAndRemove(file.path)
+}
+
+// scrubAndRemove renames path to random names of the same length, truncates
+// it if it is a file and finally unlinks it.
+func scrubAndRemove(path string) error {
+	dir := filepath.Dir(path)
+	current := path
+	for i := 0; i < shredRenames; i++ {
+		next, err := unusedRandomPath(dir, len(filepath.Base(path)))
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(current, next); err != nil {
+			return fmt.Errorf("rename: %w", err)
+		}
+		current = next
+		syncDir(dir)
+	}
+	info, err := os.Lstat(current)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		if err := os.Truncate(current, 0); err != nil {
+			return fmt.Errorf("truncate: %w", err)
+		}
+	}
+	if err := os.Remove(current); err != nil {
+		return fmt.Errorf("remove: %w", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// unusedRandomPath picks a random name in dir that is not taken yet, since
+// renaming onto an existing entry would replace it.
+func unusedRandomPath(dir string, n int) (string, error) {
+	for attempt := 0; attempt < 16; attempt++ {
+		name, err := randomName(n)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			return path, nil
+		}
+		n++
+	}
+	return "", errors.New("no unused random name found")
+}
+
+func randomName(n int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, max(n, 1))
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+// syncDir flushes directory entries to disk. Not every platform can fsync
+// a directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
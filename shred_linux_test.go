@@ -0,0 +1,62 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// Symlinks and special files are listed as skipped and left in place, the
+// folders holding them with them, and nothing they point to is touched.
+func TestShredPathsSkipsLinksAndSpecialFiles(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "secret")
+	writeTree(t, root, 3000, "a.txt", "links/b.txt")
+	outside := filepath.Join(dir, "outside.txt")
+	if err := os.WriteFile(outside, []byte("outside"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "links", "to-outside")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	dirLink := filepath.Join(root, "links", "to-dir")
+	if err := os.Symlink(dir, dirLink); err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(root, "pipe")
+	if err := unix.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if items := shredSummary([]string{root}); len(items) != 1 || items[0].Files != 2 || items[0].Skipped != 3 {
+		t.Errorf("summary %+v", items)
+	}
+	result := ShredPaths([]string{root}, MethodByID("random"), 100, newJobControl().ctl(), nil)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if want := []string{dirLink, link, fifo}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("skipped %v, want %v", result.Skipped, want)
+	}
+	if result.Files != 2 {
+		t.Errorf("shredded %d files, want 2", result.Files)
+	}
+	// Deepest folder first, each left in place as it still holds links.
+	if want := []string{filepath.Join(root, "links"), root}; !reflect.DeepEqual(failurePaths(result.Failures), want) {
+		t.Errorf("failures %+v, want %v", result.Failures, want)
+	}
+	for _, path := range []string{link, dirLink, fifo} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	if b, err := os.ReadFile(outside); err != nil || string(b) != "outside" {
+		t.Errorf("link target is now %q, %v", b, err)
+	}
+}
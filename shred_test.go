@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeTree creates files under dir, given by their slash-separated paths
+// relative to it, each holding size bytes.
+func writeTree(t *testing.T, dir string, size int, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte{0x42}, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func failurePaths(failures []ShredFailure) []string {
+	paths := []string{}
+	for _, f := range failures {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestShredPaths(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "secret")
+	writeTree(t, root, 3000, "a.txt", "sub/b.txt", "sub/deeper/c.txt", "sub/deeper/deepest/d.txt")
+	writeTree(t, dir, 0, "empty.txt")
+	writeTree(t, dir, 100, "keep.txt")
+
+	var last ShredProgress
+	result := ShredPaths([]string{root, filepath.Join(dir, "empty.txt")}, MethodByID("dod-3"), 100, newJobControl().ctl(), func(p ShredProgress) { last = p })
+	if result.Err != nil || len(result.Failures) > 0 {
+		t.Fatalf("err %v, failures %+v", result.Err, result.Failures)
+	}
+	if result.Files != 5 || result.BytesWritten != 4*3000*3 {
+		t.Errorf("shredded %d files, %d bytes", result.Files, result.BytesWritten)
+	}
+	if last.FilesTotal != 5 || last.Done != last.Total || last.Total != 4*3000*4 {
+		t.Errorf("last progress %+v", last)
+	}
+	// The folders went deepest first, then the folder selected; no renamed
+	// leftovers remain beside them.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep.txt" {
+		t.Errorf("left behind %v", entries)
+	}
+}
+
+// A file that disappears or is swapped for another after it was listed is
+// recorded as a failure, and the other files are still shredded.
+func TestShredPathsFailures(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "secret")
+	writeTree(t, root, 3000, "1.txt", "2.txt", "3.txt", "4.txt")
+	victim := filepath.Join(dir, "victim.txt")
+	if err := os.WriteFile(victim, []byte("not selected"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	swapped := false
+	result := ShredPaths([]string{root}, MethodByID("zero"), 100, newJobControl().ctl(), func(p ShredProgress) {
+		if swapped {
+			return
+		}
+		swapped = true
+		if err := os.Remove(filepath.Join(root, "2.txt")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(victim, filepath.Join(root, "3.txt")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	want := []string{filepath.Join(root, "2.txt"), filepath.Join(root, "3.txt"), root}
+	if got := failurePaths(result.Failures); !reflect.DeepEqual(got, want) {
+		t.Fatalf("failures %v, want %v", got, want)
+	}
+	if !errors.Is(result.Failures[0].Err, os.ErrNotExist) {
+		t.Errorf("missing file: %v", result.Failures[0].Err)
+	}
+	if got := result.Failures[1].Err.Error(); got != "file changed since it was selected" {
+		t.Errorf("swapped file: %v", got)
+	}
+	if result.Files != 2 {
+		t.Errorf("shredded %d files, want 2", result.Files)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "3.txt")); err != nil || string(b) != "not selected" {
+		t.Errorf("swapped-in file is now %q, %v", b, err)
+	}
+}
+
+func TestShredPathsCancel(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, 3000, "a.txt", "b.txt")
+	job := newJobControl()
+	job.Cancel()
+	result := ShredPaths([]string{dir}, MethodByID("zero"), 100, job.ctl(), nil)
+	if !errors.Is(result.Err, errCancelled) {
+		t.Errorf("got %v, want %v", result.Err, errCancelled)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("%d files left after cancelling, want 2", len(entries))
+	}
+}
+
+func TestShredSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, 1000, "folder/a", "folder/sub/b", "single.txt")
+	items := shredSummary([]string{filepath.Join(dir, "folder"), filepath.Join(dir, "single.txt"), filepath.Join(dir, "missing")})
+	want := []shredItem{
+		{Path: filepath.Join(dir, "folder"), Dir: true, Files: 2, Size: 2000},
+		{Path: filepath.Join(dir, "single.txt"), Files: 1, Size: 1000},
+		{Path: filepath.Join(dir, "missing"), Skipped: 1},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %+v\nwant %+v", items, want)
+	}
+}
+
+func TestScrubAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, 10, "other", "name.txt")
+	if err := scrubAndRemove(filepath.Join(dir, "name.txt")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"other"}) {
+		t.Errorf("left %v", names)
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Signatures are read and erased in whole aligned blocks, since raw devices
+// on Windows only accept sector-aligned I/O.
+const signatureBlockSize = 4096
+
+var errSignaturesRemain = errors.New("signatures remain after erasing")
+
+// Signature is a filesystem, RAID, LVM or partition-table magic found on a
+// device. Magic is what was matched at Offset; Erase is the range zeroed to
+// remove it.
+type Signature struct {
+	Path   string
+	Type   string
+	Offset uint64
+	Magic  []byte
+	Erase  ByteRange
+}
+
+func (s Signature) String() string {
+	if len(s.Magic) == 0 {
+		return fmt.Sprintf("%s at 0x%x (%s)", s.Type, s.Offset, formatBytes(s.Erase.Length))
+	}
+	magic := []string{}
+	for _, b := range s.Magic {
+		magic = append(magic, fmt.Sprintf("%02x", b))
+	}
+	return fmt.Sprintf("%s at 0x%x (%s)", s.Type, s.Offset, strings.Join(magic, " "))
+}
+
+type signatureProbe struct {
+	r    io.ReaderAt
+	path string
+	size uint64
+	sigs []Signature
+}
+
+// read returns n bytes at off, or nil when they lie beyond the device.
+func (p *signatureProbe) read(off uint64, n int) ([]byte, error) {
+	if off > p.size || uint64(n) > p.size-off {
+		return nil, nil
+	}
+	start := off &^ (signatureBlockSize - 1)
+	end := min((off+uint64(n)+signatureBlockSize-1)&^(signatureBlockSize-1), p.size)
+	buf := make([]byte, end-start)
+	if read, err := p.r.ReadAt(buf, int64(start)); err != nil && !(errors.Is(err, io.EOF) && read == len(buf)) {
+		return nil, fmt.Errorf("read at offset %d: %w", start, err)
+	}
+	return buf[off-start : off-start+uint64(n)], nil
+}
+
+// match reports whether magic is found at off and records it if so.
+func (p *signatureProbe) match(typ string, off uint64, magic []byte) (bool, error) {
+	b, err := p.read(off, len(magic))
+	if err != nil || !bytes.Equal(b, magic) {
+		return false, err
+	}
+	p.add(typ, off, magic, ByteRange{Offset: off, Length: uint64(len(magic))})
+	return true, nil
+}
+
+func (p *signatureProbe) add(typ string, off uint64, magic []byte, erase ByteRange) {
+	for _, s := range p.sigs {
+		if s.Offset == off && s.Type == typ {
+			return
+		}
+	}
+	p.sigs = append(p.sigs, Signature{Path: p.path, Type: typ, Offset: off, Magic: magic, Erase: erase})
+}
+
+var signatureProbes = []func(*signatureProbe) error{
+	probeMBR,
+	probeGPT,
+	probeExt,
+	probeXFS,
+	probeBtrfs,
+	probeNTFS,
+	probeFAT,
+	probeExFAT,
+	probeSwap,
+	probeLVM,
+	probeMD,
+}
+
+// findSignatures lists every known signature on r, which is size bytes
+// long and lives at path.
+func findSignatures(r io.ReaderAt, path string, size uint64) ([]Signature, error) {
+	p := &signatureProbe{r: r, path: path, size: size}
+	for _, probe := range signatureProbes {
+		if err := probe(p); err != nil {
+			return p.sigs, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return p.sigs, nil
+}
+
+// probeSignatures lists the signatures on a target, its partitions and the
+// members of its stack without opening anything for writing.
+func probeSignatures(t WipeTarget) ([]Signature, error) {
+	sigs := []Signature{}
+	for _, part := range append(append([]WipeTarget{}, t.Parts...), t) {
+		f, err := os.Open(part.Path)
+		if err != nil {
+			return sigs, err
+		}
+		size, regular, err := regularFileSize(f)
+		if !regular {
+			size = part.Size
+		}
+		if err == nil {
+			var found []Signature
+			found, err = findSignatures(f, part.Path, size)
+			sigs = append(sigs, found...)
+		}
+		f.Close()
+		if err != nil {
+			return sigs, err
+		}
+	}
+	for _, m := range t.Members {
+		found, err := probeSignatures(m)
+		sigs = append(sigs, found...)
+		if err != nil {
+			return sigs, err
+		}
+	}
+	return sigs, nil
+}
+
+// wipeSignatures erases every signature on f, then reads the device again
+// to make sure none survived.
+func wipeSignatures(f *deviceFile, path string, size uint64) ([]Signature, error) {
+	// A disk's partitions may have just been written through their own nodes.
+	if err := dropCache(f.File); err != nil {
+		return nil, err
+	}
+	sigs, err := findSignatures(f, path, size)
+	if err != nil || len(sigs) == 0 {
+		return sigs, err
+	}
+	for _, s := range sigs {
+		start := s.Erase.Offset &^ (signatureBlockSize - 1)
+		end := min((s.Erase.Offset+s.Erase.Length+signatureBlockSize-1)&^(signatureBlockSize-1), size)
+		buf := make([]byte, end-start)
+		if read, err := f.ReadAt(buf, int64(start)); err != nil && !(errors.Is(err, io.EOF) && read == len(buf)) {
+			return sigs, fmt.Errorf("%s: read at offset %d: %w", path, start, err)
+		}
+		clear(buf[s.Erase.Offset-start : s.Erase.Offset-start+s.Erase.Length])
+		if _, err := f.WriteAt(buf, int64(start)); err != nil {
+			return sigs, fmt.Errorf("%s: erase %s: %w", path, s.Type, err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return sigs, err
+	}
+	if err := dropCache(f.File); err != nil {
+		return sigs, err
+	}
+	if err := checkSignatures(f, path, size); err != nil {
+		return sigs, err
+	}
+	return sigs, nil
+}
+
+// checkSignatures fails if any known signature is still on f.
+func checkSignatures(f io.ReaderAt, path string, size uint64) error {
+	remaining, err := findSignatures(f, path, size)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		found := []string{}
+		for _, s := range remaining {
+			found = append(found, s.String())
+		}
+		return fmt.Errorf("%s: %w: %s", path, errSignaturesRemain, strings.Join(found, ", "))
+	}
+	return nil
+}
+
+var bootSignature = []byte{0x55, 0xAA}
+
+func probeMBR(p *signatureProbe) error {
+	b, err := p.read(0, 512)
+	if b == nil || err != nil || !bytes.Equal(b[510:], bootSignature) {
+		return err
+	}
+	// FAT, NTFS and exFAT boot sectors end in the same 0x55AA.
+	if isFATBootSector(b) || bytes.Equal(b[3:11], []byte("NTFS    ")) || bytes.Equal(b[3:11], []byte("EXFAT   ")) || bytes.Equal(b[:4], []byte("XFSB")) {
+		return nil
+	}
+	typ := "dos"
+	for i := 0; i < 4; i++ {
+		entry := b[446+16*i:]
+		if entry[0] != 0x00 && entry[0] != 0x80 {
+			return nil
+		}
+		if entry[4] == 0xEE {
+			typ = "PMBR"
+		}
+	}
+	// Zero the partition table along with the signature.
+	p.add(typ, 510, bootSignature, ByteRange{Offset: 446, Length: 66})
+	return nil
+}
+
+var gptMagic = []byte("EFI PART")
+
+func probeGPT(p *signatureProbe) error {
+	for _, sector := range []uint64{512, 4096} {
+		primary, err := p.gptHeader("gpt", sector, sector)
+		if err != nil {
+			return err
+		}
+		backup := p.size/sector*sector - sector
+		if primary != nil {
+			if lba := binary.LittleEndian.Uint64(primary[32:40]); lba < p.size/sector {
+				backup = lba * sector
+			}
+		}
+		if _, err := p.gptHeader("gpt (backup)", backup, sector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gptHeader records the GPT header at off and its partition entry array.
+func (p *signatureProbe) gptHeader(typ string, off, sector uint64) ([]byte, error) {
+	if off < sector {
+		return nil, nil
+	}
+	hdr, err := p.read(off, 92)
+	if hdr == nil || err != nil || !bytes.Equal(hdr[:8], gptMagic) {
+		return nil, err
+	}
+	p.add(typ, off, gptMagic, ByteRange{Offset: off, Length: sector})
+	entries := binary.LittleEndian.Uint64(hdr[72:80]) * sector
+	length := uint64(binary.LittleEndian.Uint32(hdr[80:84])) * uint64(binary.LittleEndian.Uint32(hdr[84:88]))
+	if length > 0 && length <= 4<<20 && entries >= sector && entries+length <= p.size {
+		p.add(typ+" entries", entries, nil, ByteRange{Offset: entries, Length: length})
+	}
+	return hdr, nil
+}
+
+func probeExt(p *signatureProbe) error {
+	sb, err := p.read(1024, 0x68)
+	if sb == nil || err != nil || !isExtSuperblock(sb) {
+		return err
+	}
+	p.add(extVariant(sb), 0x438, sb[0x38:0x3A], ByteRange{Offset: 0x438, Length: 2})
+	return nil
+}
+
+// isExtSuperblock checks the ext magic and, since random data left by a
+// wipe matches two bytes once in 65536 devices, the revision level and the
+// block size as well.
+func isExtSuperblock(sb []byte) bool {
+	return bytes.Equal(sb[0x38:0x3A], []byte{0x53, 0xEF}) &&
+		binary.LittleEndian.Uint32(sb[0x4C:]) <= 1 &&
+		binary.LittleEndian.Uint32(sb[0x18:]) <= 6
+}
+
+// extVariant tells ext2, ext3, ext4 and external journals apart by the
+// feature flags in their superblock.
+func extVariant(sb []byte) string {
+	compat := binary.LittleEndian.Uint32(sb[0x5C:])
+	incompat := binary.LittleEndian.Uint32(sb[0x60:])
+	roCompat := binary.LittleEndian.Uint32(sb[0x64:])
+	switch {
+	case incompat&0x8 != 0:
+		return "jbd"
+	case incompat&(0x40|0x80|0x200) != 0 || roCompat&(0x8|0x10|0x20|0x40) != 0:
+		return "ext4"
+	case compat&0x4 != 0:
+		return "ext3"
+	}
+	return "ext2"
+}
+
+func probeXFS(p *signatureProbe) error {
+	_, err := p.match("xfs", 0, []byte("XFSB"))
+	return err
+}
+
+func probeBtrfs(p *signatureProbe) error {
+	// The primary superblock and its mirrors at 64 MiB and 256 GiB.
+	for _, off := range []uint64{64 << 10, 64 << 20, 256 << 30} {
+		if _, err := p.match("btrfs", off+0x40, []byte("_BHRfS_M")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bootSector records an NTFS, FAT or exFAT boot sector at off: the
+// filesystem name at nameOff and the trailing 0x55AA.
+func (p *signatureProbe) bootSector(typ string, off uint64, nameOff int, name string) (bool, error) {
+	found, err := p.match(typ, off+uint64(nameOff), []byte(name))
+	if !found || err != nil {
+		return false, err
+	}
+	_, err = p.match(typ, off+510, bootSignature)
+	return true, err
+}
+
+func probeNTFS(p *signatureProbe) error {
+	found, err := p.bootSector("ntfs", 0, 3, "NTFS    ")
+	if err != nil {
+		return err
+	}
+	// NTFS keeps a copy of its boot sector in the last sector of the volume.
+	backups := []uint64{p.size/512*512 - 512}
+	if found {
+		b, err := p.read(0, 0x30)
+		if err != nil {
+			return err
+		}
+		bps := uint64(binary.LittleEndian.Uint16(b[0x0B:]))
+		backups = append(backups, binary.LittleEndian.Uint64(b[0x28:])*bps)
+	}
+	for _, off := range backups {
+		if off == 0 {
+			continue
+		}
+		if _, err := p.bootSector("ntfs (backup)", off, 3, "NTFS    "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isFATBootSector(b []byte) bool {
+	if b[0] != 0xEB && b[0] != 0xE9 {
+		return false
+	}
+	for _, name := range []string{"FAT12   ", "FAT16   ", "FAT     "} {
+		if bytes.Equal(b[0x36:0x3E], []byte(name)) {
+			return true
+		}
+	}
+	return bytes.Equal(b[0x52:0x5A], []byte("FAT32   "))
+}
+
+func probeFAT(p *signatureProbe) error {
+	b, err := p.read(0, 512)
+	if b == nil || err != nil {
+		return err
+	}
+	if isFATBootSector(b) {
+		p.add("vfat", 0, b[:1], ByteRange{Offset: 0, Length: 3})
+		nameOff := 0x36
+		if bytes.Equal(b[0x52:0x5A], []byte("FAT32   ")) {
+			nameOff = 0x52
+		}
+		if _, err := p.bootSector("vfat", 0, nameOff, string(b[nameOff:nameOff+8])); err != nil {
+			return err
+		}
+	}
+	// FAT32 keeps a backup boot sector, normally at sector 6.
+	backup := uint64(6 * 512)
+	if isFATBootSector(b) && bytes.Equal(b[0x52:0x5A], []byte("FAT32   ")) {
+		if sec := uint64(binary.LittleEndian.Uint16(b[0x32:])); sec != 0 {
+			backup = sec * uint64(binary.LittleEndian.Uint16(b[0x0B:]))
+		}
+	}
+	if b, err = p.read(backup, 512); b == nil || err != nil || !isFATBootSector(b) {
+		return err
+	}
+	p.add("vfat (backup)", backup, b[:1], ByteRange{Offset: backup, Length: 3})
+	_, err = p.bootSector("vfat (backup)", backup, 0x52, "FAT32   ")
+	return err
+}
+
+func probeExFAT(p *signatureProbe) error {
+	if _, err := p.bootSector("exfat", 0, 3, "EXFAT   "); err != nil {
+		return err
+	}
+	// The backup boot region starts at sector 12.
+	for _, sector := range []uint64{512, 4096} {
+		if _, err := p.bootSector("exfat (backup)", 12*sector, 3, "EXFAT   "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func probeSwap(p *signatureProbe) error {
+	// The signature ends the first page, whose size depends on the host.
+	for _, page := range []uint64{4096, 8192, 16384, 65536} {
+		for _, magic := range []string{"SWAPSPACE2", "SWAP-SPACE"} {
+			if _, err := p.match("swap", page-10, []byte(magic)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func probeLVM(p *signatureProbe) error {
+	// The PV label may sit in any of the first four sectors.
+	for sector := uint64(0); sector < 4; sector++ {
+		b, err := p.read(sector*512, 32)
+		if b == nil || err != nil {
+			return err
+		}
+		if bytes.Equal(b[:8], []byte("LABELONE")) && bytes.Equal(b[24:32], []byte("LVM2 001")) {
+			p.add("LVM2_member", sector*512+24, b[24:32], ByteRange{Offset: sector * 512, Length: 32})
+		}
+	}
+	return nil
+}
+
+func probeMD(p *signatureProbe) error {
+	if p.size < 128<<10 {
+		return nil
+	}
+	offsets := []uint64{
+		(p.size &^ (64<<10 - 1)) - 64<<10, // 0.90
+		((p.size >> 9) - 16) &^ 7 << 9,    // 1.0
+		0,                                 // 1.1
+		4096,                              // 1.2
+	}
+	for _, off := range offsets {
+		// 0.90 superblocks are written in host byte order.
+		for _, magic := range [][]byte{{0xFC, 0x4E, 0x2B, 0xA9}, {0xA9, 0x2B, 0x4E, 0xFC}} {
+			if _, err := p.match("linux_raid_member", off, magic); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mdImage() []byte {
+	img := make([]byte, 256<<10)
+	binary.LittleEndian.PutUint32(img[4096:], 0xA92B4EFC)
+	return img
+}
+
+func signatureTypes(sigs []Signature) []string {
+	types := []string{}
+	for _, s := range sigs {
+		types = append(types, s.Type)
+	}
+	return types
+}
+
+func TestWipeSignatures(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   []byte
+		types []string
+	}{
+		{"dos", mbrImage(), []string{"dos"}},
+		{"gpt", gptImage(512, 4<<20, testGPTParts(512)), []string{"PMBR", "gpt", "gpt entries", "gpt (backup)", "gpt (backup) entries"}},
+		{"gpt 4k", gptImage(4096, 4<<20, testGPTParts(4096)), []string{"PMBR", "gpt", "gpt entries", "gpt (backup)", "gpt (backup) entries"}},
+		{"ext4", extImage(), []string{"ext4"}},
+		{"xfs", xfsImage(), []string{"xfs"}},
+		{"btrfs", btrfsImage(), []string{"btrfs"}},
+		{"ntfs", ntfsImage(), []string{"ntfs", "ntfs"}},
+		{"fat16", fatImage("FAT16", 20000), []string{"vfat", "vfat", "vfat"}},
+		{"fat32", fatImage("FAT32", 70000), []string{"vfat", "vfat", "vfat"}},
+		{"exfat", exfatImage(), []string{"exfat", "exfat"}},
+		{"swap", swapImage(), []string{"swap"}},
+		{"lvm", lvmImage(), []string{"LVM2_member"}},
+		{"md", mdImage(), []string{"linux_raid_member"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := uint64(len(tt.img))
+			sigs, err := findSignatures(bytes.NewReader(tt.img), "disk.img", size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signatureTypes(sigs); !reflect.DeepEqual(got, tt.types) {
+				t.Fatalf("found %q, want %q", got, tt.types)
+			}
+			if err := checkSignatures(bytes.NewReader(tt.img), "disk.img", size); !errors.Is(err, errSignaturesRemain) {
+				t.Errorf("checkSignatures: got %v, want %v", err, errSignaturesRemain)
+			}
+
+			path := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(path, tt.img, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			erased, err := wipeSignatures(&deviceFile{File: f, size: size}, path, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signatureTypes(erased); !reflect.DeepEqual(got, tt.types) {
+				t.Errorf("erased %q, want %q", got, tt.types)
+			}
+
+			// Only the erase ranges are zeroed.
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]byte{}, tt.img...)
+			for _, s := range erased {
+				clear(want[s.Erase.Offset : s.Erase.Offset+s.Erase.Length])
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("bytes outside the signatures changed")
+			}
+			if fs, err := readFilesystem(bytes.NewReader(got), size); err != nil || fs.Type != "" {
+				t.Errorf("still identified as %+v, %v", fs, err)
+			}
+		})
+	}
+}
+
+// Random data left by the last pass of a wipe is not mistaken for an ext
+// superblock when it happens to carry the two-byte magic.
+func TestCheckSignaturesRandom(t *testing.T) {
+	img := make([]byte, 1<<20)
+	if _, err := rand.Read(img); err != nil {
+		t.Fatal(err)
+	}
+	// Rule out the other signatures' chance matches.
+	img[510] = 0
+	copy(img[1024+0x38:], []byte{0x53, 0xEF})
+	img[1024+0x4C+3] = 0xA7
+	if err := checkSignatures(bytes.NewReader(img), "disk.img", uint64(len(img))); err != nil {
+		t.Error(err)
+	}
+
+	for _, field := range []struct {
+		off   int
+		value uint32
+	}{
+		{0x4C, 2},  // s_rev_level
+		{0x18, 12}, // s_log_block_size
+	} {
+		img := extImage()
+		binary.LittleEndian.PutUint32(img[1024+field.off:], field.value)
+		if sigs, err := findSignatures(bytes.NewReader(img), "disk.img", uint64(len(img))); err != nil || len(sigs) > 0 {
+			t.Errorf("0x%x = %d: found %v, %v", field.off, field.value, sigs, err)
+		}
+	}
+	img = extImage()
+	binary.LittleEndian.PutUint32(img[1024+0x4C:], 1)
+	binary.LittleEndian.PutUint32(img[1024+0x18:], 2)
+	if sigs, _ := findSignatures(bytes.NewReader(img), "disk.img", uint64(len(img))); !reflect.DeepEqual(signatureTypes(sigs), []string{"ext4"}) {
+		t.Errorf("revision 1 with 4 KiB blocks: found %v", sigs)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+var errStackActive = errors.New("storage stack is still active")
+
+// stackRole describes what a device whose filesystem type is fsType is a
+// member of, for the types that belong to a larger device rather than
+// holding a filesystem themselves.
+func stackRole(fsType string) string {
+	switch fsType {
+	case "LVM2_member":
+		return "LVM physical volume"
+	case "linux_raid_member":
+		return "md RAID member"
+	case "mpath_member":
+		return "multipath path"
+	case "crypto_LUKS":
+		return "dm-crypt (LUKS) volume"
+	}
+	return ""
+}
+
+// stackSummary describes the stack a device belongs to for the target list,
+// or returns "" when it is not part of one.
+func stackSummary(t WipeTarget) string {
+	stack, members := targetStack(t)
+	role := stackRole(t.FSType)
+	if len(stack) == 0 {
+		return role
+	}
+	summary := "part of " + strings.Join(stack, ", ")
+	if len(members) > 0 {
+		summary += " with " + strings.Join(members, ", ")
+	}
+	if role != "" {
+		summary = role + ", " + summary
+	}
+	return summary
+}
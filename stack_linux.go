@@ -0,0 +1,276 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DM_DEV_REMOVE, _IOWR(0xfd, 4, struct dm_ioctl)
+	dmDevRemove = 0xc138fd04
+	// STOP_ARRAY, _IO(MD_MAJOR, 0x32)
+	mdStopArray = 0x932
+)
+
+// dmIoctl is struct dm_ioctl from linux/dm-ioctl.h.
+type dmIoctl struct {
+	Version     [3]uint32
+	DataSize    uint32
+	DataStart   uint32
+	TargetCount uint32
+	OpenCount   int32
+	Flags       uint32
+	EventNr     uint32
+	Padding     uint32
+	Dev         uint64
+	Name        [128]byte
+	UUID        [129]byte
+	Data        [7]byte
+}
+
+// stackDevice is a device-mapper or md device in a target's stack. Depth
+// counts the stacked devices below it, so the outermost device has the
+// highest.
+type stackDevice struct {
+	Name  string
+	Depth int
+}
+
+func sysfsBlockFile(name, file string) string {
+	b, err := os.ReadFile(filepath.Join("/sys/class/block", name, file))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func isMD(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/block", name, "md"))
+	return err == nil
+}
+
+// isStacked reports whether name is a device-mapper or md device.
+func isStacked(name string) bool {
+	if _, err := os.Stat(filepath.Join("/sys/class/block", name, "dm")); err == nil {
+		return true
+	}
+	return isMD(name)
+}
+
+// isStackedDevice reports whether the device at path is a device-mapper or
+// md device.
+func isStackedDevice(path string) bool {
+	dir, err := sysfsBlockDir(path)
+	return err == nil && isStacked(filepath.Base(dir))
+}
+
+// partitionParent returns the disk a partition is on, or "" for a device
+// that is not a partition.
+func partitionParent(name string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err != nil {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(dir))
+}
+
+// stackTitle describes a stacked device: md0 (raid1), LVM volume vg-root,
+// dm-crypt luks-..., multipath mpatha.
+func stackTitle(name string) string {
+	if level := sysfsBlockFile(name, "md/level"); level != "" {
+		return fmt.Sprintf("%s (%s)", name, level)
+	}
+	dmName := sysfsBlockFile(name, "dm/name")
+	uuid := sysfsBlockFile(name, "dm/uuid")
+	switch {
+	case strings.HasPrefix(uuid, "LVM-"):
+		return "LVM volume " + dmName
+	case strings.HasPrefix(uuid, "CRYPT-"):
+		return "dm-crypt " + dmName
+	case strings.HasPrefix(uuid, "mpath-"):
+		return "multipath " + dmName
+	case strings.HasPrefix(uuid, "part"):
+		return "partition mapping " + dmName
+	}
+	return "device-mapper " + ternary(dmName != "", dmName, name)
+}
+
+// deviceStack walks up from the named devices through the device-mapper
+// and md devices built on them, and back down to every other device those
+// are built on. It returns the stacked devices, outermost first, and the
+// kernel names of the other members.
+func deviceStack(names []string) ([]stackDevice, []string) {
+	start := map[string]bool{}
+	for _, name := range names {
+		start[name] = true
+	}
+	seen := map[string]bool{}
+	stacked := []string{}
+	members := []string{}
+	queue := append([]string{}, names...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		dir := filepath.Join("/sys/class/block", name)
+		parent := partitionParent(name)
+		switch {
+		case isStacked(name):
+			stacked = append(stacked, name)
+			slaves, _ := os.ReadDir(filepath.Join(dir, "slaves"))
+			for _, slave := range slaves {
+				queue = append(queue, slave.Name())
+			}
+		case parent != "" && isStacked(parent):
+			// A partition of an array goes away with the array.
+		case !start[name]:
+			members = append(members, name)
+		}
+		holders, _ := os.ReadDir(filepath.Join(dir, "holders"))
+		for _, holder := range holders {
+			queue = append(queue, holder.Name())
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if _, err := os.Stat(filepath.Join(dir, e.Name(), "partition")); err == nil {
+				queue = append(queue, e.Name())
+			}
+		}
+	}
+
+	depth := map[string]int{}
+	var depthOf func(name string) int
+	depthOf = func(name string) int {
+		if d, ok := depth[name]; ok {
+			return d
+		}
+		depth[name] = 0
+		d := 0
+		slaves, _ := os.ReadDir(filepath.Join("/sys/class/block", name, "slaves"))
+		for _, slave := range slaves {
+			below := slave.Name()
+			if parent := partitionParent(below); parent != "" && isStacked(parent) {
+				below = parent
+			}
+			if isStacked(below) {
+				d = max(d, depthOf(below)+1)
+			}
+		}
+		depth[name] = d
+		return d
+	}
+	stack := []stackDevice{}
+	for _, name := range stacked {
+		stack = append(stack, stackDevice{Name: name, Depth: depthOf(name)})
+	}
+	sort.SliceStable(stack, func(i, j int) bool { return stack[i].Depth > stack[j].Depth })
+	return stack, members
+}
+
+func targetNames(t WipeTarget) []string {
+	names := []string{}
+	for _, target := range t.devices() {
+		if dir, err := sysfsBlockDir(target.Path); err == nil {
+			names = append(names, filepath.Base(dir))
+		}
+	}
+	return names
+}
+
+// targetStack describes the stacked devices built on the target, outermost
+// first, and returns the paths of the other devices they are built on.
+func targetStack(t WipeTarget) ([]string, []string) {
+	stack, members := deviceStack(targetNames(t))
+	titles := []string{}
+	for _, d := range stack {
+		titles = append(titles, stackTitle(d.Name))
+	}
+	paths := []string{}
+	for _, m := range members {
+		paths = append(paths, "/dev/"+m)
+	}
+	return titles, paths
+}
+
+// teardownStack removes the device-mapper devices and stops the md arrays
+// built on the target and its members, outermost first, and makes sure
+// nothing is left. The metadata of LVM, md and LUKS stays on the members
+// until their signatures are erased.
+func teardownStack(t WipeTarget) error {
+	stack, _ := deviceStack(targetNames(t))
+	for _, d := range stack {
+		title := stackTitle(d.Name)
+		var err error
+		if isMD(d.Name) {
+			err = stopArray(d.Name)
+		} else {
+			err = removeMapping(d.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("deactivating %s: %w", title, err)
+		}
+	}
+	if remaining, _ := targetStack(t); len(remaining) > 0 {
+		return fmt.Errorf("%s: %w: %s", t.Path, errStackActive, strings.Join(remaining, ", "))
+	}
+	return nil
+}
+
+// removeMapping removes a device-mapper device, as dmsetup remove does.
+func removeMapping(name string) error {
+	majmin := strings.SplitN(sysfsBlockFile(name, "dev"), ":", 2)
+	if len(majmin) != 2 {
+		return fmt.Errorf("%s: no device number", name)
+	}
+	major, err := strconv.ParseUint(majmin[0], 10, 32)
+	if err != nil {
+		return err
+	}
+	minor, err := strconv.ParseUint(majmin[1], 10, 32)
+	if err != nil {
+		return err
+	}
+	control, err := os.OpenFile("/dev/mapper/control", os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer control.Close()
+	req := dmIoctl{
+		Version:   [3]uint32{4, 0, 0},
+		DataSize:  uint32(unsafe.Sizeof(dmIoctl{})),
+		DataStart: uint32(unsafe.Sizeof(dmIoctl{})),
+		Dev:       unix.Mkdev(uint32(major), uint32(minor)),
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, control.Fd(), dmDevRemove, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// stopArray stops an md array, as mdadm --stop does.
+func stopArray(name string) error {
+	f, err := os.OpenFile("/dev/"+name, os.O_RDONLY|unix.O_EXCL, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), mdStopArray, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
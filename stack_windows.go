@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+// targetStack finds nothing on Windows, where Wipr does not look into
+// Storage Spaces or dynamic disks.
+func targetStack(t WipeTarget) ([]string, []string) {
+	return nil, nil
+}
+
+func isStackedDevice(path string) bool {
+	return false
+}
+
+func teardownStack(t WipeTarget) error {
+	return nil
+}
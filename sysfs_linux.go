@@ -0,0 +1,85 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysfsBlockDir resolves a device node such as /dev/sda1 to its directory
+// under /sys/class/block.
+func sysfsBlockDir(devPath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeDevice == 0 || info.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("%s is not a block device", devPath)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", filepath.Base(resolved)))
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// sysfsQueueAttr reads a queue attribute of a disk, or of the disk that
+// holds a partition.
+func sysfsQueueAttr(devPath string, attr string) (string, error) {
+	dir, err := sysfsBlockDir(devPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		dir = filepath.Dir(dir)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "queue", attr))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func sysfsQueueUint(devPath string, attr string) (uint64, error) {
+	s, err := sysfsQueueAttr(devPath, attr)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// blockNameOf returns the kernel name (sda1, dm-0, ...) of the block device
+// with the given "major:minor" number.
+func blockNameOf(dev string) (string, bool) {
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/dev/block", dev))
+	if err != nil {
+		return "", false
+	}
+	return filepath.Base(dir), true
+}
+
+// blockStack returns name together with every device below it: the disk
+// holding a partition and the slaves of device-mapper and md devices.
+func blockStack(name string) []string {
+	names := []string{name}
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return names
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		names = append(names, blockStack(filepath.Base(filepath.Dir(dir)))...)
+	}
+	slaves, _ := os.ReadDir(filepath.Join(dir, "slaves"))
+	for _, slave := range slaves {
+		names = append(names, blockStack(slave.Name())...)
+	}
+	return names
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaypipes/ghw"
+)
+
+// targetList is what a scan of the drives, partitions and image files
+// found, keyed by tree ID.
+type targetList struct {
+	disks   []string
+	parts   map[string][]string
+	parent  map[string]string
+	titles  map[string]string
+	details map[string]string
+	// locked holds the entries that host the running system.
+	locked map[string]bool
+	// unlisted holds the entries the system does not list: image files and
+	// partitions found only by reading a partition table.
+	unlisted map[string]WipeTarget
+	// filesystems holds what the superblock of each entry says is on it.
+	filesystems map[string]FSInfo
+}
+
+// targetScan is a targetList with the drives and partitions behind it, the
+// image files that have gone away, and what could not be read.
+type targetScan struct {
+	targetList
+	drives     map[string]*ghw.Disk
+	partitions map[string]*ghw.Partition
+	missing    []string
+	errs       []error
+}
+
+// targetTree lists every disk with its partitions under it, each with a
+// check box. Checking a disk selects the whole disk, so its partitions show
+// as checked and cannot be toggled on their own.
+type targetTree struct {
+	targetList
+	tree     *widget.Tree
+	total    *widget.Label
+	checked  map[string]bool
+	selected string
+	images   []string
+	// scanning is set while a scan runs in the background; rescan asks
+	// for another once it is done, and after runs when the list is current.
+	scanning bool
+	rescan   bool
+	after    []func()
+	// lastErr is the scan error last shown, so rescans do not repeat it.
+	lastErr string
+	// OnChanged is called whenever the set of checked targets changes.
+	OnChanged func()
+	// OnSelected is called with the selected entry and its disk whenever
+	// the selection, or the disk under it, changes; "" when there is none.
+	OnSelected func(disk, id string)
+	// OnError is called with what a scan could not read, once until the
+	// problem changes.
+	OnError func(err error)
+}
+
+func newTargetTree() *targetTree {
+	t := &targetTree{total: widget.NewLabel("Reading drives..."), checked: map[string]bool{}}
+	t.tree = widget.NewTree(t.childUIDs, t.isBranch, t.createNode, t.updateNode)
+	t.tree.OnSelected = func(id widget.TreeNodeID) {
+		t.selected = id
+		t.selectionChanged()
+	}
+	t.Reload()
+	return t
+}
+
+// Reload reads the drives and partitions again. Entries that are still
+// there stay checked; those that went away are dropped. Reading every
+// device can take seconds, so it happens in the background; a Reload asked
+// for while one runs is folded into a single scan after it.
+func (t *targetTree) Reload() {
+	t.reload(nil)
+}
+
+// reload is Reload, calling then once the list is up to date.
+func (t *targetTree) reload(then func()) {
+	if then != nil {
+		t.after = append(t.after, then)
+	}
+	if t.scanning {
+		t.rescan = true
+		return
+	}
+	t.scanning = true
+	images := slices.Clone(t.images)
+	go func() {
+		s := scanTargets(images)
+		fyne.Do(func() {
+			t.scanning = false
+			t.apply(s)
+			if t.rescan {
+				t.rescan = false
+				t.reload(nil)
+				return
+			}
+			after := t.after
+			t.after = nil
+			for _, f := range after {
+				f()
+			}
+		})
+	}()
+}
+
+// scanTargets reads the drives, partitions and image files, and what is on
+// them. It runs off the UI goroutine and touches nothing the UI uses.
+func scanTargets(images []string) *targetScan {
+	s := &targetScan{targetList: targetList{
+		parts:       map[string][]string{},
+		parent:      map[string]string{},
+		titles:      map[string]string{},
+		details:     map[string]string{},
+		locked:      map[string]bool{},
+		unlisted:    map[string]WipeTarget{},
+		filesystems: map[string]FSInfo{},
+	}}
+	s.disks, s.drives = List_Drives()
+	protected, err := protectedDevices()
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("cannot tell which devices the system runs from, so none are marked protected: %w", err))
+	}
+	lock := func(id, path string) {
+		if reason, ok := protected[path]; ok {
+			s.titles[id] += " [protected: " + reason + "]"
+			s.locked[id] = true
+		}
+	}
+	// probe reads the entry's superblock and describes what is on it for
+	// its title. Devices that cannot be read are listed without.
+	probe := func(id string, target WipeTarget) string {
+		fs, _ := probeFilesystem(target)
+		s.filesystems[id] = fs
+		if fs.Type == "" {
+			return ""
+		}
+		return " " + fs.String()
+	}
+	for _, disk := range s.disks {
+		d := s.drives[disk]
+		target := diskTarget(d)
+		s.titles[disk] = fmt.Sprintf("%s (%s, %s)", d.Model, target.Path, formatBytes(d.SizeBytes)) + probe(disk, target)
+		s.details[disk] = target.ID.String()
+		if stack := stackSummary(target); stack != "" {
+			s.details[disk] += "; " + stack
+		}
+		lock(disk, target.Path)
+	}
+	var names []string
+	names, s.partitions = List_Partitions()
+	for _, name := range names {
+		p := s.partitions[name]
+		for _, disk := range s.disks {
+			if p.Disk != nil && s.drives[disk].Name == p.Disk.Name {
+				s.parts[disk] = append(s.parts[disk], name)
+				s.parent[name] = disk
+				break
+			}
+		}
+		target := partitionTarget(p)
+		s.titles[name] = fmt.Sprintf("%s (%s)", target.Path, formatBytes(p.SizeBytes)) + probe(name, target)
+		lock(name, target.Path)
+		// The disk's serial and WWN are already shown on its own row.
+		s.details[name] = DeviceID{PartUUID: target.ID.PartUUID, ByID: target.ID.ByID}.String()
+		if stack := stackSummary(target); stack != "" {
+			s.details[name] += "; " + stack
+		}
+	}
+	// Disks whose partitions the system does not know, such as loop devices
+	// without partition scanning or disks with a damaged table, and image
+	// files get theirs from the partition table.
+	addTable := func(disk string, target WipeTarget) {
+		parts, table, err := tableTargets(target)
+		if err != nil {
+			if target.Disk == "" && !errors.Is(err, errNoPartitionTable) {
+				s.errs = append(s.errs, fmt.Errorf("%s: %w", target.Path, err))
+			}
+			return
+		}
+		if len(table.Problems) > 0 {
+			s.details[disk] += "; " + table.String() + ": " + strings.Join(table.Problems, "; ")
+		}
+		for _, p := range parts {
+			id := disk + "#" + p.Name
+			s.parts[disk] = append(s.parts[disk], id)
+			s.parent[id] = disk
+			s.unlisted[id] = p
+			s.titles[id] = fmt.Sprintf("%s (%s)", p.Name, formatBytes(p.Size)) + probe(id, p) + " [partition table only]"
+			s.details[id] = DeviceID{PartUUID: p.ID.PartUUID}.String()
+			lock(id, p.Path)
+		}
+	}
+	for _, disk := range s.disks {
+		if len(s.parts[disk]) == 0 {
+			addTable(disk, diskTarget(s.drives[disk]))
+		}
+	}
+	for _, path := range images {
+		info, err := os.Stat(path)
+		if err != nil {
+			s.errs = append(s.errs, err)
+			s.missing = append(s.missing, path)
+			continue
+		}
+		id := "image:" + path
+		s.disks = append(s.disks, id)
+		s.unlisted[id] = WipeTarget{Name: filepath.Base(path), Path: path, Size: uint64(info.Size())}
+		s.titles[id] = fmt.Sprintf("Image %s (%s)", path, formatBytes(uint64(info.Size()))) + probe(id, s.unlisted[id])
+		s.details[id] = "image file"
+		addTable(id, s.unlisted[id])
+	}
+	for id, fs := range s.filesystems {
+		if fs.UUID != "" {
+			s.details[id] += "; UUID " + fs.UUID
+		}
+	}
+	return s
+}
+
+// apply shows what a scan found.
+func (t *targetTree) apply(s *targetScan) {
+	clear(driveMap)
+	maps.Copy(driveMap, s.drives)
+	clear(partitionMap)
+	maps.Copy(partitionMap, s.partitions)
+	t.targetList = s.targetList
+	t.images = slices.DeleteFunc(t.images, func(path string) bool { return slices.Contains(s.missing, path) })
+	wasChecked := t.checked
+	t.checked = map[string]bool{}
+	for id := range wasChecked {
+		if _, ok := t.titles[id]; ok && wasChecked[id] && !t.locked[id] {
+			t.checked[id] = true
+		}
+	}
+	if _, ok := t.titles[t.selected]; !ok {
+		t.selected = ""
+		t.tree.UnselectAll()
+	}
+	t.tree.Refresh()
+	t.tree.OpenAllBranches()
+	t.changed()
+	t.selectionChanged()
+	err := errors.Join(s.errs...)
+	if err == nil {
+		t.lastErr = ""
+	} else if t.OnError != nil && err.Error() != t.lastErr {
+		t.lastErr = err.Error()
+		t.OnError(err)
+	}
+}
+
+func (t *targetTree) selectionChanged() {
+	if t.OnSelected == nil {
+		return
+	}
+	disk := t.selected
+	if parent, ok := t.parent[disk]; ok {
+		disk = parent
+	}
+	t.OnSelected(disk, t.selected)
+}
+
+// SelectPartition selects a partition and checks it for wiping, unless it
+// is protected or its whole disk is already checked.
+func (t *targetTree) SelectPartition(id string) {
+	disk, ok := t.parent[id]
+	if !ok {
+		return
+	}
+	if !t.locked[id] && !t.checked[disk] && !t.checked[id] {
+		t.checked[id] = true
+		t.tree.RefreshItem(id)
+		t.changed()
+	}
+	t.tree.ScrollTo(id)
+	t.tree.Select(id)
+}
+
+// AddImage lists a disk image file, with the partitions in its partition
+// table, and selects it.
+func (t *targetTree) AddImage(path string) {
+	id := "image:" + path
+	show := func() {
+		t.tree.ScrollTo(id)
+		t.tree.Select(id)
+	}
+	if slices.Contains(t.images, path) && t.titles[id] != "" {
+		show()
+		return
+	}
+	if !slices.Contains(t.images, path) {
+		t.images = append(t.images, path)
+	}
+	t.reload(show)
+}
+
+// Watch reloads the list whenever a device is added, removed or changed,
+// once a burst of events has settled.
+func (t *targetTree) Watch() {
+	var timer *time.Timer
+	subscribeDevices(func(deviceEvent) {
+		fyne.Do(func() {
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(hotplugSettle, func() { fyne.Do(t.Reload) })
+		})
+	})
+}
+
+func (t *targetTree) childUIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	if id == "" {
+		return t.disks
+	}
+	return t.parts[id]
+}
+
+func (t *targetTree) isBranch(id widget.TreeNodeID) bool {
+	return id == "" || len(t.parts[id]) > 0
+}
+
+func (t *targetTree) createNode(branch bool) fyne.CanvasObject {
+	detail := widget.NewLabel("")
+	detail.TextStyle = fyne.TextStyle{Italic: true}
+	return container.NewHBox(widget.NewCheck("", nil), container.NewVBox(widget.NewLabel(""), detail))
+}
+
+func (t *targetTree) updateNode(id widget.TreeNodeID, branch bool, o fyne.CanvasObject) {
+	row := o.(*fyne.Container)
+	check := row.Objects[0].(*widget.Check)
+	labels := row.Objects[1].(*fyne.Container)
+	title := labels.Objects[0].(*widget.Label)
+	labels.Objects[1].(*widget.Label).SetText(t.details[id])
+
+	check.OnChanged = nil
+	disk, isPart := t.parent[id]
+	title.TextStyle = fyne.TextStyle{Bold: !isPart}
+	title.SetText(t.titles[id])
+	if isPart && t.checked[disk] {
+		check.SetChecked(true)
+		check.Disable()
+		return
+	}
+	if t.locked[id] {
+		check.SetChecked(false)
+		check.Disable()
+		return
+	}
+	check.Enable()
+	check.SetChecked(t.checked[id])
+	check.OnChanged = func(checked bool) {
+		t.checked[id] = checked
+		if _, isPart := t.parent[id]; !isPart {
+			for _, part := range t.parts[id] {
+				t.tree.RefreshItem(part)
+			}
+		}
+		t.changed()
+	}
+}
+
+func (t *targetTree) changed() {
+	targets := t.Targets()
+	var size uint64
+	for _, target := range targets {
+		size += target.Size
+	}
+	if len(targets) == 0 {
+		t.total.SetText("Nothing selected")
+	} else {
+		t.total.SetText(fmt.Sprintf("%d targets selected, %s will be destroyed", len(targets), formatBytes(size)))
+	}
+	if t.OnChanged != nil {
+		t.OnChanged()
+	}
+}
+
+// Targets returns every checked disk, and every checked partition whose
+// disk is not checked as a whole.
+func (t *targetTree) Targets() []WipeTarget {
+	targets := []WipeTarget{}
+	for _, disk := range t.disks {
+		if t.checked[disk] {
+			targets = append(targets, t.diskTarget(disk))
+			continue
+		}
+		for _, part := range t.parts[disk] {
+			if t.checked[part] {
+				targets = append(targets, t.partTarget(part))
+			}
+		}
+	}
+	return targets
+}
+
+// diskTarget returns the listed disk or image file, with the partitions
+// from its partition table where the system knows of none.
+func (t *targetTree) diskTarget(id string) WipeTarget {
+	target, ok := t.unlisted[id]
+	if !ok {
+		target = diskTarget(driveMap[id])
+	}
+	if len(target.Parts) == 0 {
+		for _, part := range t.parts[id] {
+			target.Parts = append(target.Parts, t.partTarget(part))
+		}
+	}
+	for i, p := range target.Parts {
+		for _, part := range t.parts[id] {
+			if pt := t.partTarget(part); pt.extent() == p.extent() {
+				target.Parts[i] = pt
+			}
+		}
+	}
+	return target.withFilesystem(t.filesystems[id])
+}
+
+func (t *targetTree) partTarget(id string) WipeTarget {
+	target, ok := t.unlisted[id]
+	if !ok {
+		target = partitionTarget(partitionMap[id])
+	}
+	return target.withFilesystem(t.filesystems[id])
+}
+
+// Layout returns a listed disk with the IDs of its partitions and the
+// partitions themselves, for the partition bar.
+func (t *targetTree) Layout(disk string) diskLayout {
+	if _, ok := t.titles[disk]; !ok || disk == "" {
+		return diskLayout{}
+	}
+	layout := diskLayout{Disk: t.diskTarget(disk), IDs: t.parts[disk]}
+	for _, part := range t.parts[disk] {
+		layout.Parts = append(layout.Parts, t.partTarget(part))
+	}
+	return layout
+}
+
+// Partition returns the checked partition when it is the only target.
+func (t *targetTree) Partition() *ghw.Partition {
+	var found *ghw.Partition
+	for _, disk := range t.disks {
+		if t.checked[disk] {
+			return nil
+		}
+		for _, part := range t.parts[disk] {
+			if !t.checked[part] {
+				continue
+			}
+			if _, ok := t.unlisted[part]; ok || found != nil {
+				return nil
+			}
+			found = partitionMap[part]
+		}
+	}
+	return found
+}
+
+// Container lays the tree out with room for a few disks and the running
+// total below it.
+func (t *targetTree) Container() fyne.CanvasObject {
+	space := canvas.NewRectangle(color.Transparent)
+	space.SetMinSize(fyne.NewSize(0, 160))
+	return container.NewBorder(nil, t.total, nil, nil, container.NewStack(space, t.tree))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"strings"
+)
+
+const (
+	verifyBlockSize       = 64 << 10
+	maxReportedMismatches = 32
+)
+
+var errVerifyFailed = errors.New("verification failed")
+
+type ByteRange struct {
+	Offset uint64
+	Length uint64
+}
+
+// VerifyReport is the outcome of reading a pass back. Mismatches holds at
+// most maxReportedMismatches ranges; MismatchedBytes counts all of them.
+// Seed picked the sampled blocks when Percent is below 100.
+type VerifyReport struct {
+	Pass            int
+	Device          string
+	Percent         int
+	Seed            uint64
+	BytesChecked    uint64
+	MismatchedBytes uint64
+	Mismatches      []ByteRange
+}
+
+func (r VerifyReport) String() string {
+	if len(r.Mismatches) == 0 {
+		return fmt.Sprintf("%s checked (%d%%), no mismatches", formatBytes(r.BytesChecked), r.Percent)
+	}
+	ranges := []string{}
+	for _, m := range r.Mismatches {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", m.Offset, m.Offset+m.Length-1))
+	}
+	return fmt.Sprintf("%s of %s checked (%d%%) did not match at bytes %s",
+		formatBytes(r.MismatchedBytes), formatBytes(r.BytesChecked), r.Percent, strings.Join(ranges, ", "))
+}
+
+func (r *VerifyReport) addMismatch(off uint64, got, expected []byte) {
+	for i := 0; i < len(got); i++ {
+		if got[i] == expected[i] {
+			continue
+		}
+		start := i
+		for i < len(got) && got[i] != expected[i] {
+			i++
+		}
+		length := uint64(i - start)
+		r.MismatchedBytes += length
+		if n := len(r.Mismatches); n > 0 && r.Mismatches[n-1].Offset+r.Mismatches[n-1].Length == off+uint64(start) {
+			r.Mismatches[n-1].Length += length
+		} else if n < maxReportedMismatches {
+			r.Mismatches = append(r.Mismatches, ByteRange{Offset: off + uint64(start), Length: length})
+		}
+	}
+}
+
+// verifySeed seeds the choice of sampled blocks; tests fix it.
+var verifySeed = mrand.Uint64
+
+// verifySampled decides whether the block at off is read back. The same
+// seed always draws the same sample.
+func verifySampled(seed, off uint64, percent int) bool {
+	return mrand.NewPCG(seed, off).Uint64()%100 < uint64(percent)
+}
+
+// verifyPass re-reads the target and compares it with what pass wrote,
+// either completely or on a random sample of percent of its blocks. The
+// first and last block are always checked.
+func verifyPass(r io.ReaderAt, size uint64, pass Pass, percent int, ctl wipeControl, progress func(checked uint64)) (VerifyReport, error) {
+	fill, err := pass.generator()
+	if err != nil {
+		return VerifyReport{Percent: percent}, err
+	}
+	return verifyFill(r, size, fill, percent, ctl, progress)
+}
+
+// verifyFill is verifyPass with the expected bytes at each offset of r
+// coming from fill.
+func verifyFill(r io.ReaderAt, size uint64, fill func(buf []byte, off uint64) error, percent int, ctl wipeControl, progress func(checked uint64)) (VerifyReport, error) {
+	report := VerifyReport{Percent: percent, Seed: verifySeed()}
+	got := make([]byte, verifyBlockSize)
+	expected := make([]byte, verifyBlockSize)
+	for off := uint64(0); off < size; off += verifyBlockSize {
+		if err := ctl.checkpoint(); err != nil {
+			return report, err
+		}
+		n := uint64(verifyBlockSize)
+		if remaining := size - off; remaining < n {
+			n = remaining
+		}
+		last := off+n == size
+		if percent < 100 && off != 0 && !last && !verifySampled(report.Seed, off, percent) {
+			continue
+		}
+		if err := fill(expected[:n], off); err != nil {
+			return report, err
+		}
+		read, err := r.ReadAt(got[:n], int64(off))
+		if err != nil && !(errors.Is(err, io.EOF) && uint64(read) == n) {
+			return report, fmt.Errorf("read at offset %d: %w", off, err)
+		}
+		if !bytes.Equal(got[:n], expected[:n]) {
+			report.addMismatch(off, got[:n], expected[:n])
+		}
+		report.BytesChecked += n
+		progress(report.BytesChecked)
+	}
+	return report, nil
+}
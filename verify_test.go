@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// verifyTarget writes what pass puts on a target of size bytes to a file,
+// then corrupts the bytes at the given offsets.
+func verifyTarget(t *testing.T, pass Pass, size uint64, corrupt ...uint64) *os.File {
+	t.Helper()
+	gen, err := pass.generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, size)
+	if err := gen(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range corrupt {
+		data[off] ^= 0xFF
+	}
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestVerifyPassFull(t *testing.T) {
+	const size = 40*verifyBlockSize + 1234
+	pass := Pass{Kind: PassRandom, Seed: "verify test"}
+	bad := uint64(10*verifyBlockSize + 100)
+	f := verifyTarget(t, pass, size, bad, bad+1, bad+2)
+
+	var progress uint64
+	vr, err := verifyPass(f, size, pass, 100, newJobControl().ctl(), func(n uint64) { progress = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ByteRange{{Offset: bad, Length: 3}}
+	if !reflect.DeepEqual(vr.Mismatches, want) || vr.MismatchedBytes != 3 || vr.BytesChecked != size || progress != size {
+		t.Errorf("got %+v, progress %d", vr, progress)
+	}
+	if s := vr.String(); !strings.Contains(s, "655460-655462") {
+		t.Errorf("report %q does not name the corrupt bytes", s)
+	}
+
+	f = verifyTarget(t, pass, size)
+	if vr, err := verifyPass(f, size, pass, 100, newJobControl().ctl(), func(uint64) {}); err != nil || len(vr.Mismatches) > 0 || vr.BytesChecked != size {
+		t.Errorf("intact target: %+v, %v", vr, err)
+	}
+}
+
+func TestVerifyPassSampled(t *testing.T) {
+	const blocks = 100
+	const size = blocks * verifyBlockSize
+	pass := patternPass(0x92, 0x49, 0x24)
+	bad := uint64(77*verifyBlockSize + 5)
+	f := verifyTarget(t, pass, size, bad)
+	randomSeed := verifySeed
+	t.Cleanup(func() { verifySeed = randomSeed })
+
+	for _, percent := range []int{1, 10, 50, 99} {
+		for seed := uint64(0); seed < 10; seed++ {
+			verifySeed = func() uint64 { return seed }
+			// The first and last block, and the ones the seed picks.
+			var want uint64
+			for off := uint64(0); off < size; off += verifyBlockSize {
+				if off == 0 || off+verifyBlockSize == size || verifySampled(seed, off, percent) {
+					want += verifyBlockSize
+				}
+			}
+			vr, err := verifyPass(f, size, pass, percent, newJobControl().ctl(), func(uint64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vr.Seed != seed || vr.Percent != percent || vr.BytesChecked != want {
+				t.Errorf("%d%%, seed %d: checked %d bytes, want %d", percent, seed, vr.BytesChecked, want)
+			}
+			found := len(vr.Mismatches) > 0
+			if sampled := verifySampled(seed, bad-5, percent); found != sampled {
+				t.Errorf("%d%%, seed %d: corrupt block sampled %v, reported %v", percent, seed, sampled, found)
+			}
+			again, _ := verifyPass(f, size, pass, percent, newJobControl().ctl(), func(uint64) {})
+			if !reflect.DeepEqual(again, vr) {
+				t.Errorf("%d%%, seed %d: second run gave %+v, first %+v", percent, seed, again, vr)
+			}
+		}
+	}
+
+	// The first and last block are read back at any percentage.
+	f = verifyTarget(t, pass, size, 0, size-1)
+	vr, err := verifyPass(f, size, pass, 1, newJobControl().ctl(), func(uint64) {})
+	if err != nil || !reflect.DeepEqual(vr.Mismatches, []ByteRange{{0, 1}, {size - 1, 1}}) {
+		t.Errorf("got %+v, %v", vr, err)
+	}
+}
+
+func TestVerifySampledRate(t *testing.T) {
+	for _, percent := range []int{1, 10, 50, 90} {
+		sampled := 0
+		const n = 100000
+		for i := uint64(0); i < n; i++ {
+			if verifySampled(42, i*verifyBlockSize, percent) {
+				sampled++
+			}
+		}
+		if got := float64(sampled) * 100 / n; got < float64(percent)*0.9 || got > float64(percent)*1.1 {
+			t.Errorf("%d%%: sampled %.2f%% of blocks", percent, got)
+		}
+	}
+}
+
+func TestVerifyPassMismatchRanges(t *testing.T) {
+	const size = 4 * verifyBlockSize
+	pass := zeroPass
+	// A run across a block boundary and more separate runs than are listed.
+	corrupt := []uint64{verifyBlockSize - 2, verifyBlockSize - 1, verifyBlockSize, verifyBlockSize + 1}
+	for i := uint64(0); i < maxReportedMismatches+10; i++ {
+		corrupt = append(corrupt, 2*verifyBlockSize+2*i)
+	}
+	f := verifyTarget(t, pass, size, corrupt...)
+	vr, err := verifyPass(f, size, pass, 100, newJobControl().ctl(), func(uint64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vr.Mismatches) != maxReportedMismatches || vr.MismatchedBytes != uint64(len(corrupt)) {
+		t.Errorf("%d ranges, %d bytes; want %d, %d", len(vr.Mismatches), vr.MismatchedBytes, maxReportedMismatches, len(corrupt))
+	}
+	if vr.Mismatches[0] != (ByteRange{Offset: verifyBlockSize - 2, Length: 4}) {
+		t.Errorf("first range %+v", vr.Mismatches[0])
+	}
+
+	// A target shorter than it should be cannot be read back.
+	if _, err := verifyPass(f, size+verifyBlockSize, pass, 100, newJobControl().ctl(), func(uint64) {}); err == nil {
+		t.Error("read past the end of the file without an error")
+	}
+	job := newJobControl()
+	job.Cancel()
+	if _, err := verifyPass(f, size, pass, 100, job.ctl(), func(uint64) {}); !errors.Is(err, errCancelled) {
+		t.Errorf("got %v, want %v", err, errCancelled)
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const progressInterval = 100 * time.Millisecond
+
+// progressView is the window shown while a wipe or shred runs in place of
+// the main window. Its Cancel button pauses the job until the operator
+// confirms.
+type progressView struct {
+	window       fyne.Window
+	countLabel   *widget.Label
+	passLabel    *widget.Label
+	sizeLabel    *widget.Label
+	textArea     *widget.Label
+	prg          *widget.ProgressBar
+	ctl          wipeControl
+	lastUpdate   time.Time
+	parentWindow *fyne.Window
+}
+
+// enterWipeMode hides the main window and the tray items that would show
+// or quit it while a job runs.
+func enterWipeMode(window *fyne.Window) {
+	isWiping = true
+	(*window).Hide()
+	if quitWinSystray != nil {
+		quitWinSystray.Disable()
+	}
+	if showWinSystray != nil {
+		showWinSystray.Disable()
+	}
+}
+
+func leaveWipeMode(window *fyne.Window) {
+	isWiping = false
+	(*window).Show()
+	if quitWinSystray != nil {
+		quitWinSystray.Enable()
+	}
+	if showWinSystray != nil {
+		showWinSystray.Enable()
+	}
+}
+
+func showProgress(app fyne.App, window *fyne.Window, heading string) *progressView {
+	enterWipeMode(window)
+
+	v := &progressView{
+		window:       app.NewWindow("Wiping in progress"),
+		countLabel:   widget.NewLabel(""),
+		passLabel:    widget.NewLabel(""),
+		sizeLabel:    widget.NewLabel(""),
+		textArea:     widget.NewLabel(""),
+		prg:          widget.NewProgressBar(),
+		parentWindow: window,
+	}
+	v.textArea.Wrapping = fyne.TextWrapBreak
+	v.countLabel.Hide()
+
+	job := newJobControl()
+	v.ctl = job.ctl()
+	cancelFunc := func() {
+		job.Pause()
+		dialog.ShowConfirm("Cancel?", "Are you sure you want to cancel?", func(confirm bool) {
+			if confirm {
+				job.Cancel()
+			} else {
+				job.Resume()
+			}
+		}, v.window)
+	}
+	cancelButton := widget.NewButton("Cancel", cancelFunc)
+
+	progressBox := container.NewVBox(widget.NewLabel(heading), v.countLabel, v.passLabel, v.sizeLabel, v.prg, v.textArea, layout.NewSpacer(), cancelButton)
+	v.window.SetContent(progressBox)
+	v.window.Resize(fyne.NewSize(400, 240))
+	v.window.SetFixedSize(true)
+	v.window.CenterOnScreen()
+	v.window.SetCloseIntercept(cancelFunc)
+	v.window.Show()
+	return v
+}
+
+// throttled reports whether an update arriving now should be dropped to
+// keep the UI from being flooded by the engine.
+func (v *progressView) throttled(done, total uint64) bool {
+	if time.Since(v.lastUpdate) < progressInterval && done < total {
+		return true
+	}
+	v.lastUpdate = time.Now()
+	return false
+}
+
+// close restores the main window. It must be called from the job goroutine.
+func (v *progressView) close() {
+	fyne.Do(func() {
+		leaveWipeMode(v.parentWindow)
+		v.window.Close()
+	})
+}
+
+func wipeTargets(app fyne.App, window *fyne.Window, targets []WipeTarget, method *WipeMethod) (success bool, err error) {
+	targets, stacks, err := gatherStacks(targets)
+	if err != nil {
+		return false, err
+	}
+	if len(stacks) == 0 {
+		return preflightTargets(app, window, targets, method, false)
+	}
+	msg := "Wiping only part of a storage stack leaves its data recoverable on the other members and breaks it.\n\n" +
+		strings.Join(stacks, "\n") +
+		"\n\nWipr will deactivate these stacks, then wipe every member listed and erase their LVM, md and LUKS metadata along with the target."
+	dialog.ShowConfirm("Wipe the whole stack?", msg, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if _, err := preflightTargets(app, window, targets, method, true); err != nil {
+			dialog.ShowError(err, *window)
+		}
+	}, *window)
+	return true, nil
+}
+
+// gatherStacks adds the other members of each target's LVM, md or
+// device-mapper stack to the target, and folds selected targets that belong
+// to an earlier target's stack into it. It describes every stack found.
+func gatherStacks(targets []WipeTarget) ([]WipeTarget, []string, error) {
+	grouped := []WipeTarget{}
+	titles := [][]string{}
+	owner := map[string]int{}
+	for _, t := range targets {
+		i, merged := 0, false
+		for _, d := range t.devices() {
+			if i, merged = owner[d.extent()]; merged {
+				break
+			}
+		}
+		if merged {
+			// Wipe it as a member, in place of any of its partitions the
+			// stack already lists.
+			covered := map[string]bool{}
+			for _, d := range t.devices() {
+				covered[d.extent()] = true
+				owner[d.extent()] = i
+			}
+			members := []WipeTarget{}
+			for _, m := range grouped[i].Members {
+				if !covered[m.extent()] {
+					members = append(members, m)
+				}
+			}
+			grouped[i].Members = append(members, t)
+			continue
+		}
+		stack, paths := targetStack(t)
+		for _, path := range paths {
+			m, ok := targetByPath(path)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s: %s, a member of its stack, is not in the device list", t.Path, path)
+			}
+			t.Members = append(t.Members, m)
+		}
+		if isStackedDevice(t.Path) && len(t.Members) > 0 {
+			// The stack is wiped through its members; the device selected
+			// goes away when it is deactivated.
+			leader := t.Members[0]
+			leader.Members = t.Members[1:]
+			t = leader
+		}
+		for _, d := range t.devices() {
+			owner[d.extent()] = len(grouped)
+		}
+		grouped = append(grouped, t)
+		titles = append(titles, stack)
+	}
+	stacks := []string{}
+	for i, t := range grouped {
+		if len(titles[i]) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s is part of %s", t.Path, strings.Join(titles[i], ", "))
+		if len(t.Members) > 0 {
+			members := []string{}
+			for _, m := range t.Members {
+				members = append(members, m.Path)
+			}
+			line += ", together with " + strings.Join(members, ", ")
+		}
+		stacks = append(stacks, line)
+	}
+	return grouped, stacks, nil
+}
+
+// preflightTargets looks for anything still using the targets, then asks
+// for confirmation. With teardown, the stacked devices on the targets are
+// left to the engine to deactivate.
+func preflightTargets(app fyne.App, window *fyne.Window, targets []WipeTarget, method *WipeMethod, teardown bool) (success bool, err error) {
+	found := []string{}
+	inUse := []targetMount{}
+	holders := []deviceHolder{}
+	for _, t := range targets {
+		if err := method.Check(t); err != nil {
+			return false, fmt.Errorf("%s: %w", t.Path, err)
+		}
+		if err := checkProtected(t); err != nil {
+			return false, err
+		}
+		held, err := targetHolders(t)
+		if err != nil {
+			return false, fmt.Errorf("finding processes using %s: %w", t.Path, err)
+		}
+		if teardown {
+			held = unstacked(held)
+		}
+		holders = append(holders, held...)
+		mounts, err := targetMounts(t)
+		if err != nil {
+			return false, err
+		}
+		inUse = append(inUse, mounts...)
+		sigs, err := probeSignatures(t)
+		if err != nil {
+			return false, err
+		}
+		for _, s := range sigs {
+			found = append(found, s.Path+": "+s.String())
+		}
+	}
+	if len(holders) > 0 {
+		showHolders(app, window, targets, method, teardown, holders)
+		return true, nil
+	}
+	confirmWipe(*window, targets, inUse, found, func() {
+		runWipe(app, window, targets, method, len(inUse) > 0, teardown)
+	})
+	return true, nil
+}
+
+// showHolders lists what still holds the targets open. The wipe cannot go
+// ahead from here; the operator closes those programs or devices and checks
+// again.
+func showHolders(app fyne.App, window *fyne.Window, targets []WipeTarget, method *WipeMethod, teardown bool, holders []deviceHolder) {
+	list := widget.NewLabel(holderList(holders))
+	list.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(500, 200))
+	content := container.NewBorder(widget.NewLabel("These must be closed or removed before wiping:"), nil, nil, nil, scroll)
+	dialog.ShowCustomConfirm("Targets in use", "Check Again", "Cancel", content, func(retry bool) {
+		if !retry {
+			return
+		}
+		if _, err := preflightTargets(app, window, targets, method, teardown); err != nil {
+			dialog.ShowError(err, *window)
+		}
+	}, *window)
+}
+
+// jobRow is one device's line in the progress window of a parallel wipe.
+type jobRow struct {
+	status     *widget.Label
+	prg        *widget.ProgressBar
+	cancelBtn  *widget.Button
+	fraction   float64
+	lastUpdate time.Time
+}
+
+func runWipe(app fyne.App, window *fyne.Window, targets []WipeTarget, method *WipeMethod, unmount, teardown bool) {
+	enterWipeMode(window)
+	progressWindow := app.NewWindow("Wiping in progress")
+	overallLabel := widget.NewLabel(fmt.Sprintf("0 / %d finished", len(targets)))
+	overallPrg := widget.NewProgressBar()
+
+	jobs := make([]WipeJob, len(targets))
+	ctls := make([]*jobControl, len(targets))
+	rows := make([]*jobRow, len(targets))
+	rowBox := container.NewVBox()
+	for i, t := range targets {
+		jobs[i] = WipeJob{Target: t, Method: method, VerifyPercent: config.VerifyPercent, Unmount: unmount, Teardown: teardown}
+		ctls[i] = newJobControl()
+		ctl := ctls[i]
+		row := &jobRow{status: widget.NewLabel("Queued"), prg: widget.NewProgressBar()}
+		row.cancelBtn = widget.NewButton("Cancel", func() {
+			ctl.Pause()
+			dialog.ShowConfirm("Cancel?", "Cancel the wipe of "+t.Path+"?", func(confirm bool) {
+				if confirm {
+					ctl.Cancel()
+				} else {
+					ctl.Resume()
+				}
+			}, progressWindow)
+		})
+		rows[i] = row
+		heading := widget.NewLabel(fmt.Sprintf("%s (%s)", targetTitle(t), formatBytes(t.Size)))
+		heading.TextStyle = fyne.TextStyle{Bold: true}
+		rowBox.Add(container.NewBorder(nil, nil, nil, row.cancelBtn, container.NewVBox(heading, row.status, row.prg)))
+	}
+
+	cancelAll := func() {
+		running := []*jobControl{}
+		for _, ctl := range ctls {
+			if !ctl.Done() {
+				ctl.Pause()
+				running = append(running, ctl)
+			}
+		}
+		dialog.ShowConfirm("Cancel?", "Are you sure you want to cancel all wipes?", func(confirm bool) {
+			for _, ctl := range running {
+				if confirm {
+					ctl.Cancel()
+				} else {
+					ctl.Resume()
+				}
+			}
+		}, progressWindow)
+	}
+	cancelAllBtn := widget.NewButton("Cancel All", cancelAll)
+
+	header := container.NewVBox(widget.NewLabel("Wiping with "+method.Name+"..."), overallLabel, overallPrg)
+	progressWindow.SetContent(container.NewBorder(header, cancelAllBtn, nil, nil, container.NewVScroll(rowBox)))
+	progressWindow.Resize(fyne.NewSize(520, float32(min(180+110*len(targets), 700))))
+	progressWindow.CenterOnScreen()
+	progressWindow.SetCloseIntercept(cancelAll)
+	progressWindow.Show()
+
+	// A job whose device, or any member of its stack, goes away is
+	// cancelled, whether it is running or still queued.
+	removed := make([]atomic.Bool, len(jobs))
+	unsubscribe := subscribeDevices(func(ev deviceEvent) {
+		if ev.Action != "remove" || ev.Path == "" {
+			return
+		}
+		for i, job := range jobs {
+			if ctls[i].Done() {
+				continue
+			}
+			for _, d := range job.Target.devices() {
+				if d.Path == ev.Path && !removed[i].Swap(true) {
+					ctls[i].Cancel()
+					fyne.Do(func() { rows[i].status.SetText(ev.Path + " was removed") })
+				}
+			}
+		}
+	})
+	// flagRemoved reports a job cancelled for its device going away as such.
+	flagRemoved := func(i int, r WipeResult) WipeResult {
+		if removed[i].Load() && errors.Is(r.Err, errCancelled) {
+			r.Err = fmt.Errorf("%s: %w", r.Target.Path, errDeviceRemoved)
+		}
+		return r
+	}
+
+	go func() {
+		finished := 0
+		results := runJobs(jobs, ctls, func(i int, p WipeProgress) {
+			row := rows[i]
+			if time.Since(row.lastUpdate) < progressInterval && p.Done < p.Total {
+				return
+			}
+			row.lastUpdate = time.Now()
+			fyne.Do(func() {
+				if p.Total > 0 {
+					row.fraction = float64(p.Done) / float64(p.Total)
+				}
+				row.prg.SetValue(row.fraction)
+				verb := "Pass"
+				if p.Verifying {
+					verb = "Verifying pass"
+				}
+				row.status.SetText(fmt.Sprintf("%s %d / %d, %s / %s", verb, p.Pass, p.Passes, formatBytes(p.Done), formatBytes(p.Total)))
+				overall := 0.0
+				for _, r := range rows {
+					overall += r.fraction
+				}
+				overallPrg.SetValue(overall / float64(len(rows)))
+			})
+		}, func(i int, result WipeResult) {
+			result = flagRemoved(i, result)
+			fyne.Do(func() {
+				row := rows[i]
+				row.cancelBtn.Disable()
+				switch {
+				case errors.Is(result.Err, errCancelled):
+					row.status.SetText("Cancelled")
+				case result.Err != nil:
+					row.status.SetText("Failed: " + result.Err.Error())
+				default:
+					row.status.SetText(fmt.Sprintf("Done, %s written", formatBytes(result.BytesWritten)))
+				}
+				row.fraction = 1
+				row.prg.SetValue(1)
+				finished++
+				overallLabel.SetText(fmt.Sprintf("%d / %d finished", finished, len(rows)))
+			})
+		})
+
+		unsubscribe()
+		for i := range results {
+			results[i] = flagRemoved(i, results[i])
+		}
+		fyne.DoAndWait(func() {
+			leaveWipeMode(window)
+			progressWindow.Close()
+			title, msg := wipeSummary(results, method)
+			dialog.ShowInformation(title, msg, *window)
+			app.SendNotification(fyne.NewNotification(title, "Wipe Finished"))
+		})
+	}()
+}
+
+// targetTitle names a target in progress rows and reports: its path, or
+// for a partition that only the partition table knows about, its name and
+// the device it is on.
+func targetTitle(t WipeTarget) string {
+	if t.Offset > 0 {
+		return t.Name + " on " + t.Path
+	}
+	return t.Path
+}
+
+// wipeSummary reports how every job of a parallel wipe ended.
+func wipeSummary(results []WipeResult, method *WipeMethod) (string, string) {
+	var succeeded, failed, cancelled, erased int
+	var written uint64
+	lines := []string{}
+	for _, r := range results {
+		written += r.BytesWritten
+		erased += len(r.Signatures)
+		title := targetTitle(r.Target)
+		if fs := r.Target.filesystem(); fs != "" {
+			title += " (was " + fs + ")"
+		}
+		switch {
+		case errors.Is(r.Err, errCancelled):
+			cancelled++
+			lines = append(lines, title+": cancelled")
+		case r.Err != nil:
+			failed++
+			lines = append(lines, fmt.Sprintf("%s: failed: %v", title, r.Err))
+		default:
+			succeeded++
+			lines = append(lines, fmt.Sprintf("%s: wiped, %s written", title, formatBytes(r.BytesWritten)))
+			for _, vr := range r.Verifications {
+				if vr.Device != r.Target.Path {
+					lines = append(lines, fmt.Sprintf("    %s pass %d: %s", vr.Device, vr.Pass, vr))
+					continue
+				}
+				lines = append(lines, fmt.Sprintf("    pass %d: %s", vr.Pass, vr))
+			}
+		}
+	}
+	title := "Success"
+	if failed > 0 {
+		title = "Wipe Failed"
+	} else if cancelled > 0 {
+		title = "Cancelled"
+	}
+	msg := fmt.Sprintf("%d wiped, %d failed, %d cancelled.\n%s written using %s (%s), %d signatures erased.\n\n%s",
+		succeeded, failed, cancelled, formatBytes(written), method.Name, method.Standard, erased, strings.Join(lines, "\n"))
+	return title, msg
+}
+
+const maxListedFailures = 10
+
+// shredFiles lists what shredding paths will destroy, reading the folders
+// in the background, and shreds them once the operator confirms. started,
+// if set, is called when shredding begins.
+func shredFiles(app fyne.App, window *fyne.Window, paths []string, method *WipeMethod, started func()) (success bool, err error) {
+	if len(paths) == 0 {
+		return false, errors.New("no files selected")
+	}
+	if err := overwriteOnly(method); err != nil {
+		return false, err
+	}
+	go func() {
+		items := shredSummary(paths)
+		fyne.Do(func() {
+			confirmShred(*window, items, func() {
+				if started != nil {
+					started()
+				}
+				startShred(app, window, paths, method)
+			})
+		})
+	}()
+	return true, nil
+}
+
+func startShred(app fyne.App, window *fyne.Window, paths []string, method *WipeMethod) {
+	v := showProgress(app, window, "Shredding with "+method.Name+"...")
+	v.countLabel.Show()
+
+	go func() {
+		defer v.close()
+
+		result := ShredPaths(paths, method, config.VerifyPercent, v.ctl, func(p ShredProgress) {
+			if v.throttled(p.Done, p.Total) {
+				return
+			}
+			fyne.Do(func() {
+				if p.Total > 0 {
+					v.prg.SetValue(float64(p.Done) / float64(p.Total))
+				}
+				v.countLabel.SetText(fmt.Sprintf("File %d / %d", p.Files, p.FilesTotal))
+				v.sizeLabel.SetText(fmt.Sprintf("%s / %s", formatBytes(p.Done), formatBytes(p.Total)))
+				path, _ := shortenPath(p.File)
+				v.textArea.SetText(path)
+			})
+		})
+
+		fyne.DoAndWait(func() {
+			if errors.Is(result.Err, errCancelled) {
+				dialog.ShowInformation("Cancelled", fmt.Sprintf("Shredding was cancelled after %d files.", result.Files), *window)
+				return
+			}
+			if result.Err != nil {
+				dialog.ShowError(result.Err, *window)
+				return
+			}
+			msg := fmt.Sprintf("%d files shredded, %s written using %s (%s).", result.Files, formatBytes(result.BytesWritten), method.Name, method.Standard)
+			if len(result.Skipped) > 0 {
+				msg += fmt.Sprintf("\n%d symlinks or special files were skipped.", len(result.Skipped))
+			}
+			if len(result.Failures) == 0 {
+				v.prg.SetValue(1.0)
+				dialog.ShowInformation("Success", msg, *window)
+				app.SendNotification(fyne.NewNotification("Success", "Shredding Complete"))
+				return
+			}
+			failures := []string{}
+			for i, f := range result.Failures {
+				if i == maxListedFailures {
+					failures = append(failures, fmt.Sprintf("... and %d more", len(result.Failures)-i))
+					break
+				}
+				failures = append(failures, fmt.Sprintf("%s: %v", f.Path, f.Err))
+			}
+			dialog.ShowError(fmt.Errorf("%s\n%d failed:\n%s", msg, len(result.Failures), strings.Join(failures, "\n")), *window)
+		})
+	}()
+}
+
+func wipeFreeSpace(app fyne.App, window *fyne.Window, mount string, method *WipeMethod) (success bool, err error) {
+	if err := overwriteOnly(method); err != nil {
+		return false, err
+	}
+	if err := checkMountPoint(mount); err != nil {
+		return false, err
+	}
+	stats, err := fsUsage(mount)
+	if err != nil {
+		return false, err
+	}
+	v := showProgress(app, window, "Wiping free space with "+method.Name+"...")
+	v.textArea.SetText(fmt.Sprintf("%s: %s free of %s", mount, formatBytes(stats.FreeBytes), formatBytes(stats.TotalBytes)))
+
+	go func() {
+		defer v.close()
+
+		result := WipeFreeSpace(mount, method, config.VerifyPercent, v.ctl, func(p FreeSpaceProgress) {
+			if v.throttled(p.Done, p.Total) {
+				return
+			}
+			fyne.Do(func() {
+				if p.Total > 0 {
+					v.prg.SetValue(float64(p.Done) / float64(p.Total))
+				}
+				if p.Inodes {
+					v.passLabel.SetText("Overwriting free inodes")
+				} else if p.Verifying {
+					v.passLabel.SetText(fmt.Sprintf("Pass %d / %d: verifying", p.Pass, p.Passes))
+				} else {
+					v.passLabel.SetText(fmt.Sprintf("Pass %d / %d", p.Pass, p.Passes))
+					v.sizeLabel.SetText(formatBytes(p.Done) + " written")
+				}
+			})
+		})
+
+		fyne.DoAndWait(func() {
+			if errors.Is(result.Err, errCancelled) {
+				dialog.ShowInformation("Cancelled", "Free space wipe was cancelled.", *window)
+			} else if result.Err != nil {
+				dialog.ShowError(result.Err, *window)
+			} else {
+				v.prg.SetValue(1.0)
+				verified := ""
+				if result.BytesVerified > 0 {
+					verified = fmt.Sprintf(", %s read back and verified", formatBytes(result.BytesVerified))
+				}
+				inodes := fmt.Sprintf("%d free inodes overwritten", result.InodeFiles)
+				if result.InodesTruncated {
+					inodes = fmt.Sprintf("%d of the %d free inodes the filesystem reports overwritten, stopping at the limit", result.InodeFiles, result.FreeInodes)
+				}
+				dialog.ShowInformation("Success", fmt.Sprintf("Free space of %s wiped!\n%s written using %s (%s)%s, %s.",
+					mount, formatBytes(result.BytesWritten), method.Name, method.Standard, verified, inodes), *window)
+				app.SendNotification(fyne.NewNotification("Success", "Free Space Wipe Complete"))
+			}
+		})
+	}()
+
+	return true, nil
+}